@@ -0,0 +1,224 @@
+package gadb
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrNoForegroundActivity is returned when no activity is resumed, e.g. when
+// the screen is locked
+var ErrNoForegroundActivity = errors.New("no foreground activity")
+
+var (
+	// The resumed activity is reported differently across Android versions,
+	// most reliable first
+	resumedActivityPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`topResumedActivity=ActivityRecord\{\S+ u\d+ ([^/\s]+)/(\S+)`),
+		regexp.MustCompile(`mResumedActivity: ActivityRecord\{\S+ u\d+ ([^/\s]+)/(\S+)`),
+		regexp.MustCompile(`ResumedActivity: ActivityRecord\{\S+ u\d+ ([^/\s]+)/(\S+)`),
+		regexp.MustCompile(`mFocusedActivity: ActivityRecord\{\S+ u\d+ ([^/\s]+)/(\S+)`),
+	}
+	focusedWindowPattern = regexp.MustCompile(`mCurrentFocus=Window\{\S+ u\d+ ([^/\s]+)/([^\s}]+)`)
+)
+
+// ForegroundActivity returns the package and the fully qualified class name
+// of the activity in the foreground
+func (d Device) ForegroundActivity() (packageName, activityName string, err error) {
+	output, err := d.RunShellCommand("dumpsys", "activity", "activities")
+	if err != nil {
+		return "", "", fmt.Errorf("adb dumpsys activity: %w", err)
+	}
+	if packageName, activityName, ok := parseResumedActivity(output); ok {
+		return packageName, activityName, nil
+	}
+
+	// Fall back to the focused window
+	output, err = d.RunShellCommand("dumpsys", "window", "windows")
+	if err != nil {
+		return "", "", fmt.Errorf("adb dumpsys window: %w", err)
+	}
+	if m := focusedWindowPattern.FindStringSubmatch(output); m != nil {
+		return m[1], expandActivityName(m[1], m[2]), nil
+	}
+	return "", "", ErrNoForegroundActivity
+}
+
+// parseResumedActivity finds the resumed activity in the output of
+// dumpsys activity activities
+func parseResumedActivity(output string) (packageName, activityName string, ok bool) {
+	for _, p := range resumedActivityPatterns {
+		if m := p.FindStringSubmatch(output); m != nil {
+			return m[1], expandActivityName(m[1], m[2]), true
+		}
+	}
+	return "", "", false
+}
+
+// expandActivityName expands the ".Activity" short form of the activities
+// of packageName
+func expandActivityName(packageName, activity string) string {
+	if strings.HasPrefix(activity, ".") {
+		return packageName + activity
+	}
+	return activity
+}
+
+// IntentSpec describes an intent sent with am
+type IntentSpec struct {
+	Action string
+	// Data is the data URI
+	Data     string
+	MimeType string
+	// Categories are added to the intent, e.g. android.intent.category.LAUNCHER
+	Categories []string
+	// Component is the explicit target, e.g. com.example/.MainActivity
+	Component string
+	// Package restricts the intent to the components of a package
+	Package string
+	// Flags are the Intent.FLAG_* flags
+	Flags int
+	// Extras are typed by their Go type: string, bool, int, int64, float32,
+	// float64, []string, []int, []int64, []float64, or nil for a null string
+	Extras map[string]interface{}
+	// UserID sends the intent to the user (--user), nil to the current user
+	UserID *int
+}
+
+// args returns the arguments of am describing the intent
+func (i IntentSpec) args() ([]string, error) {
+	args := userArgs(i.UserID)
+	if i.Action != "" {
+		args = append(args, "-a", i.Action)
+	}
+	if i.Data != "" {
+		args = append(args, "-d", i.Data)
+	}
+	if i.MimeType != "" {
+		args = append(args, "-t", i.MimeType)
+	}
+	for _, c := range i.Categories {
+		args = append(args, "-c", c)
+	}
+	if i.Flags != 0 {
+		args = append(args, "-f", fmt.Sprintf("0x%x", i.Flags))
+	}
+
+	keys := make([]string, 0, len(i.Extras))
+	for k := range i.Extras {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		extra, err := extraArgs(k, i.Extras[k])
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, extra...)
+	}
+
+	switch {
+	case i.Component != "":
+		args = append(args, "-n", i.Component)
+	case i.Package != "":
+		args = append(args, i.Package)
+	}
+	return args, nil
+}
+
+// extraArgs returns the arguments of am adding the extra key with value
+func extraArgs(key string, value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return []string{"--esn", key}, nil
+	case string:
+		return []string{"--es", key, v}, nil
+	case bool:
+		return []string{"--ez", key, strconv.FormatBool(v)}, nil
+	case int:
+		return []string{"--ei", key, strconv.Itoa(v)}, nil
+	case int64:
+		return []string{"--el", key, strconv.FormatInt(v, 10)}, nil
+	case float32:
+		return []string{"--ef", key, strconv.FormatFloat(float64(v), 'g', -1, 32)}, nil
+	case float64:
+		return []string{"--ed", key, strconv.FormatFloat(v, 'g', -1, 64)}, nil
+	case []string:
+		// am splits arrays on commas not preceded by a backslash
+		escaped := make([]string, len(v))
+		for j, s := range v {
+			escaped[j] = strings.ReplaceAll(s, ",", `\,`)
+		}
+		return []string{"--esa", key, strings.Join(escaped, ",")}, nil
+	case []int:
+		values := make([]string, len(v))
+		for j, n := range v {
+			values[j] = strconv.Itoa(n)
+		}
+		return []string{"--eia", key, strings.Join(values, ",")}, nil
+	case []int64:
+		values := make([]string, len(v))
+		for j, n := range v {
+			values[j] = strconv.FormatInt(n, 10)
+		}
+		return []string{"--ela", key, strings.Join(values, ",")}, nil
+	case []float64:
+		values := make([]string, len(v))
+		for j, f := range v {
+			values[j] = strconv.FormatFloat(f, 'g', -1, 64)
+		}
+		return []string{"--eda", key, strings.Join(values, ",")}, nil
+	default:
+		return nil, fmt.Errorf("unsupported extra %s of type %T", key, value)
+	}
+}
+
+// StartActivity starts the activity matching intent with am start
+func (d Device) StartActivity(intent IntentSpec) error {
+	return d.am("start", intent)
+}
+
+// SendBroadcast sends intent to the broadcast receivers with am broadcast
+func (d Device) SendBroadcast(intent IntentSpec) error {
+	return d.am("broadcast", intent)
+}
+
+// StartService starts the service matching intent with am startservice
+func (d Device) StartService(intent IntentSpec) error {
+	return d.am("startservice", intent)
+}
+
+func (d Device) am(command string, intent IntentSpec) error {
+	args, err := intent.args()
+	if err != nil {
+		return fmt.Errorf("adb am %s: %w", command, err)
+	}
+
+	output, err := d.RunShellCommand("am", append([]string{command}, args...)...)
+	if err != nil {
+		return fmt.Errorf("adb am %s: %w", command, err)
+	}
+	if msg := amError(output); msg != "" {
+		return fmt.Errorf("adb am %s: %s", command, msg)
+	}
+	return nil
+}
+
+// amError returns the error reported in the output of am, if any. The
+// "Error: " message is preferred to the "Error type" code preceding it.
+func amError(output string) string {
+	var msg string
+	for _, l := range strings.Split(output, "\n") {
+		l = strings.TrimSpace(l)
+		switch {
+		case strings.HasPrefix(l, "Error: "):
+			return l
+		case msg == "" && (strings.HasPrefix(l, "Error") || strings.HasPrefix(l, "Exception occurred")):
+			msg = l
+		}
+	}
+	return msg
+}
@@ -0,0 +1,110 @@
+package gadb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseResumedActivity(t *testing.T) {
+	tests := []struct {
+		output      string
+		pkg, class  string
+		wantMissing bool
+	}{
+		{
+			// Android 10+
+			output: "  ResumedActivity: ActivityRecord{7b3f2a1 u0 com.android.settings/.Settings t12}\n" +
+				"  topResumedActivity=ActivityRecord{7b3f2a1 u0 com.android.settings/.Settings t12}\n",
+			pkg:   "com.android.settings",
+			class: "com.android.settings.Settings",
+		},
+		{
+			// Android 7-9
+			output: "    mResumedActivity: ActivityRecord{e3c1d2 u0 com.example.app/com.example.app.MainActivity t5}\n",
+			pkg:    "com.example.app",
+			class:  "com.example.app.MainActivity",
+		},
+		{
+			// Android 6 and older
+			output: "  mFocusedActivity: ActivityRecord{41ac u0 tv.danmaku.bili/.ui.splash.SplashActivity t3}\n",
+			pkg:    "tv.danmaku.bili",
+			class:  "tv.danmaku.bili.ui.splash.SplashActivity",
+		},
+		{
+			output:      "ACTIVITY MANAGER ACTIVITIES (dumpsys activity activities)\n",
+			wantMissing: true,
+		},
+	}
+
+	for _, tt := range tests {
+		pkg, class, ok := parseResumedActivity(tt.output)
+		if ok == tt.wantMissing {
+			t.Errorf("parseResumedActivity(%q) found = %v", tt.output, ok)
+			continue
+		}
+		if pkg != tt.pkg || class != tt.class {
+			t.Errorf("parseResumedActivity(%q) = %q, %q, want %q, %q", tt.output, pkg, class, tt.pkg, tt.class)
+		}
+	}
+}
+
+func TestIntentSpec_args(t *testing.T) {
+	user := 10
+	intent := IntentSpec{
+		Action:     "android.intent.action.VIEW",
+		Data:       "https://example.com/?a=1&b=2",
+		Categories: []string{"android.intent.category.BROWSABLE"},
+		Component:  "com.example/.MainActivity",
+		Flags:      0x10000000,
+		Extras: map[string]interface{}{
+			"name":    "it's a test",
+			"count":   3,
+			"enabled": true,
+			"ids":     []int64{1, 2},
+			"tags":    []string{"a,b", "c"},
+			"none":    nil,
+		},
+		UserID: &user,
+	}
+
+	got, err := intent.args()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"--user", "10",
+		"-a", "android.intent.action.VIEW",
+		"-d", "https://example.com/?a=1&b=2",
+		"-c", "android.intent.category.BROWSABLE",
+		"-f", "0x10000000",
+		"--ei", "count", "3",
+		"--ez", "enabled", "true",
+		"--ela", "ids", "1,2",
+		"--es", "name", "it's a test",
+		"--esn", "none",
+		"--esa", "tags", `a\,b,c`,
+		"-n", "com.example/.MainActivity",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("args() = %q, want %q", got, want)
+	}
+
+	if _, err := (IntentSpec{Extras: map[string]interface{}{"bad": struct{}{}}}).args(); err == nil {
+		t.Error("expected error for an unsupported extra")
+	}
+}
+
+func Test_amError(t *testing.T) {
+	tests := map[string]string{
+		"Starting: Intent { cmp=com.example/.Main }\r\n":                                                    "",
+		"Broadcasting: Intent { act=foo }\nBroadcast completed: result=0\n":                                 "",
+		"Starting: Intent { cmp=com.example/.Nope }\nError type 3\nError: Activity class does not exist.\n": "Error: Activity class does not exist.",
+		"Error type 3\n": "Error type 3",
+		"Error: Not found; no service started.\n": "Error: Not found; no service started.",
+	}
+	for output, want := range tests {
+		if got := amError(output); got != want {
+			t.Errorf("amError(%q) = %q, want %q", output, got, want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package gadb
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"os/user"
+)
+
+// adbKeyBits is the only key size accepted by adbd
+const adbKeyBits = 2048
+
+// GenerateAuthKey generates a new RSA key usable to authenticate with adbd
+func GenerateAuthKey() (*rsa.PrivateKey, error) {
+	return rsa.GenerateKey(rand.Reader, adbKeyBits)
+}
+
+// LoadAuthKey reads a PEM encoded RSA private key, such as the one the adb
+// server stores in ~/.android/adbkey
+func LoadAuthKey(path string) (*rsa.PrivateKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("adb auth key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("adb auth key %s: no PEM data found", path)
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("adb auth key %s: %w", path, err)
+		}
+		return key, nil
+
+	case "PRIVATE KEY":
+		// Newer adb versions write PKCS#8
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("adb auth key %s: %w", path, err)
+		}
+		key, ok := parsed.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("adb auth key %s: not an RSA key", path)
+		}
+		return key, nil
+
+	default:
+		return nil, fmt.Errorf("adb auth key %s: unexpected PEM type %q", path, block.Type)
+	}
+}
+
+// PublicKeyADBFormat encodes the public key of key in the format expected
+// by adbd: the base64 of Android's RSAPublicKey structure (modulus size in
+// words, n0inv, little-endian modulus and R^2 mod N, exponent) followed by
+// a user@host comment
+func PublicKeyADBFormat(key *rsa.PrivateKey) []byte {
+	words := (key.N.BitLen() + 31) / 32
+
+	// n0inv = -1 / N[0] mod 2^32
+	r32 := new(big.Int).Lsh(big.NewInt(1), 32)
+	n0inv := new(big.Int).Mod(key.N, r32)
+	n0inv.ModInverse(n0inv, r32)
+	n0inv.Sub(r32, n0inv)
+
+	// rr = (2^(32*words))^2 mod N
+	rr := new(big.Int).Lsh(big.NewInt(1), uint(64*words))
+	rr.Mod(rr, key.N)
+
+	raw := new(bytes.Buffer)
+	_ = binary.Write(raw, binary.LittleEndian, uint32(words))
+	_ = binary.Write(raw, binary.LittleEndian, uint32(n0inv.Uint64()))
+	raw.Write(littleEndian(key.N, words*4))
+	raw.Write(littleEndian(rr, words*4))
+	_ = binary.Write(raw, binary.LittleEndian, uint32(key.E))
+
+	encoded := base64.StdEncoding.EncodeToString(raw.Bytes())
+	return []byte(encoded + " " + keyComment())
+}
+
+// littleEndian returns n as a little-endian number of size bytes
+func littleEndian(n *big.Int, size int) []byte {
+	b := n.FillBytes(make([]byte, size))
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return b
+}
+
+func keyComment() string {
+	username := "unknown"
+	if u, err := user.Current(); err == nil {
+		username = u.Username
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return username + "@" + hostname
+}
@@ -0,0 +1,73 @@
+package gadb
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadAuthKey(t *testing.T) {
+	key, err := GenerateAuthKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "adbkey")
+	data := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadAuthKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Equal(key) {
+		t.Error("loaded key differs from the generated one")
+	}
+
+	if _, err := LoadAuthKey(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected error for a missing key")
+	}
+}
+
+func TestPublicKeyADBFormat(t *testing.T) {
+	key, err := GenerateAuthKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := bytes.SplitN(PublicKeyADBFormat(key), []byte(" "), 2)
+	if len(fields) != 2 {
+		t.Fatal("missing user@host comment")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(string(fields[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// len, n0inv, modulus, rr, exponent
+	if want := 4 + 4 + 256 + 256 + 4; len(raw) != want {
+		t.Fatalf("encoded length = %d, want %d", len(raw), want)
+	}
+
+	if words := binary.LittleEndian.Uint32(raw[0:]); words != 64 {
+		t.Errorf("modulus words = %d, want 64", words)
+	}
+	n0inv := binary.LittleEndian.Uint32(raw[4:])
+	n0 := binary.LittleEndian.Uint32(raw[8:])
+	if n0*n0inv != 0xffffffff {
+		t.Errorf("n0inv * n[0] = %#x, want -1 mod 2^32", n0*n0inv)
+	}
+	if !bytes.Equal(raw[8:8+256], littleEndian(key.N, 256)) {
+		t.Error("modulus mismatch")
+	}
+	if e := binary.LittleEndian.Uint32(raw[len(raw)-4:]); int(e) != key.E {
+		t.Errorf("exponent = %d, want %d", e, key.E)
+	}
+}
@@ -0,0 +1,93 @@
+package gadb
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// BackupOptions are the options used when backing up the device
+type BackupOptions struct {
+	// APK includes the APKs of the applications (-apk)
+	APK bool
+	// Shared includes the shared storage (-shared)
+	Shared bool
+	// All includes all installed applications (-all)
+	All bool
+	// ExcludeSystem excludes the system applications from All (-nosystem)
+	ExcludeSystem bool
+	// Packages are the packages to back up
+	Packages []string
+}
+
+func (o BackupOptions) args() []string {
+	var args []string
+	if o.APK {
+		args = append(args, "-apk")
+	}
+	if o.Shared {
+		args = append(args, "-shared")
+	}
+	if o.All {
+		args = append(args, "-all")
+	}
+	if o.ExcludeSystem {
+		args = append(args, "-nosystem")
+	}
+	return append(args, o.Packages...)
+}
+
+// Backup writes an Android backup (.ab) of the device to dst. The backup
+// must be confirmed on the device, so this blocks until it is or ctx is done.
+func (d Device) Backup(ctx context.Context, dst io.Writer, opts BackupOptions) error {
+	cmd := shellCommand("backup:", opts.args())
+
+	r, err := d.WithReadTimeout(0).executeCommandStreaming(cmd)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	stop := closeOnCancel(ctx, r)
+	defer stop()
+
+	_, err = io.Copy(dst, r)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("adb backup: %w", ctxErr)
+	}
+	if err != nil {
+		return fmt.Errorf("adb backup: %w", err)
+	}
+	return nil
+}
+
+// Restore restores an Android backup (.ab) read from src to the device. The
+// restore must be confirmed on the device, so this blocks until it is or ctx
+// is done.
+func (d Device) Restore(ctx context.Context, src io.Reader) error {
+	r, err := d.WithReadTimeout(0).executeCommandStreaming("restore:")
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	stop := closeOnCancel(ctx, r)
+	defer stop()
+
+	_, err = io.Copy(r, src)
+	if err == nil {
+		// Like adb, end the archive by closing the stream. Half-closing it
+		// lets us wait until the device is done with the archive.
+		if cw, ok := r.(interface{ CloseWrite() error }); ok && cw.CloseWrite() == nil {
+			_, err = io.Copy(io.Discard, r)
+		}
+	}
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("adb restore: %w", ctxErr)
+	}
+	if err != nil {
+		return fmt.Errorf("adb restore: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package gadb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBackupOptions_args(t *testing.T) {
+	opts := BackupOptions{
+		APK:           true,
+		Shared:        true,
+		All:           true,
+		ExcludeSystem: true,
+		Packages:      []string{"com.example"},
+	}
+
+	want := []string{"-apk", "-shared", "-all", "-nosystem", "com.example"}
+	if got := opts.args(); !reflect.DeepEqual(got, want) {
+		t.Errorf("args() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,70 @@
+package gadb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BatteryInfo is the battery state reported by dumpsys battery. Fields
+// missing from the report are left to their zero value.
+type BatteryInfo struct {
+	// Level is the charge level, out of Scale (usually 100)
+	Level int
+	Scale int
+	// Status is one of the BatteryManager.BATTERY_STATUS_* values, e.g. 2
+	// for charging
+	Status int
+	// Health is one of the BatteryManager.BATTERY_HEALTH_* values, e.g. 2
+	// for good
+	Health int
+	// Plugged reports whether the device is powered by AC, USB or wireless
+	Plugged bool
+	// Temperature is in tenths of a degree Celsius
+	Temperature int
+	// Voltage is in millivolts
+	Voltage int
+	Present bool
+}
+
+// BatteryInfo returns the state of the battery of the device
+func (d Device) BatteryInfo() (BatteryInfo, error) {
+	output, err := d.RunShellCommand("dumpsys", "battery")
+	if err != nil {
+		return BatteryInfo{}, fmt.Errorf("adb dumpsys battery: %w", err)
+	}
+	return parseBatteryInfo(output), nil
+}
+
+// parseBatteryInfo parses the "key: value" lines of dumpsys battery
+func parseBatteryInfo(output string) BatteryInfo {
+	var info BatteryInfo
+	for _, l := range strings.Split(output, "\n") {
+		kv := strings.SplitN(l, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		n, _ := strconv.Atoi(value)
+
+		switch key {
+		case "AC powered", "USB powered", "Wireless powered", "Dock powered":
+			info.Plugged = info.Plugged || value == "true"
+		case "status":
+			info.Status = n
+		case "health":
+			info.Health = n
+		case "present":
+			info.Present = value == "true"
+		case "level":
+			info.Level = n
+		case "scale":
+			info.Scale = n
+		case "voltage":
+			info.Voltage = n
+		case "temperature":
+			info.Temperature = n
+		}
+	}
+	return info
+}
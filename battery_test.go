@@ -0,0 +1,39 @@
+package gadb
+
+import (
+	"testing"
+)
+
+func Test_parseBatteryInfo(t *testing.T) {
+	output := "Current Battery Service state:\r\n" +
+		"  AC powered: false\r\n" +
+		"  USB powered: true\r\n" +
+		"  Wireless powered: false\r\n" +
+		"  Max charging current: 500000\r\n" +
+		"  status: 2\r\n" +
+		"  health: 2\r\n" +
+		"  present: true\r\n" +
+		"  level: 85\r\n" +
+		"  scale: 100\r\n" +
+		"  voltage: 4213\r\n" +
+		"  temperature: 251\r\n" +
+		"  technology: Li-ion\r\n"
+
+	want := BatteryInfo{
+		Level:       85,
+		Scale:       100,
+		Status:      2,
+		Health:      2,
+		Plugged:     true,
+		Temperature: 251,
+		Voltage:     4213,
+		Present:     true,
+	}
+	if got := parseBatteryInfo(output); got != want {
+		t.Errorf("parseBatteryInfo() = %+v, want %+v", got, want)
+	}
+
+	if got := parseBatteryInfo("Can't find service: battery\n"); got != (BatteryInfo{}) {
+		t.Errorf("parseBatteryInfo() = %+v, want zero", got)
+	}
+}
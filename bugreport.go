@@ -0,0 +1,116 @@
+package gadb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// bugreportzVersionPattern matches the output of bugreportz -v
+var bugreportzVersionPattern = regexp.MustCompile(`^\d+\.\d+`)
+
+// BugReport writes the bug report of the device to dst: a zip file on devices
+// with bugreportz (Android 7 and later), the plain text report otherwise.
+// Bug reports take minutes, ctx bounds the wait.
+func (d Device) BugReport(ctx context.Context, dst io.Writer) error {
+	return d.BugReportWithProgress(ctx, dst, nil)
+}
+
+// BugReportWithProgress is BugReport, calling onProgress with the percentage
+// of the report done as bugreportz reports it, if not nil. Plain text reports
+// are not followed.
+func (d Device) BugReportWithProgress(ctx context.Context, dst io.Writer, onProgress func(percent int)) error {
+	// bugreportz -v prints its version, e.g. "1.1", to stderr, and fails
+	// with "not found" on devices without it
+	output, err := d.RunShellCommandContext(ctx, "bugreportz -v 2>&1")
+	if err != nil {
+		return fmt.Errorf("adb bugreportz: %w", err)
+	}
+	if !bugreportzVersionPattern.MatchString(strings.TrimSpace(output)) {
+		return d.bugReportText(ctx, dst)
+	}
+
+	var remotePath, failure string
+	err = d.RunShellCommandLines(ctx, func(line string) bool {
+		status, value := parseBugreportzLine(line)
+		switch status {
+		case "PROGRESS":
+			if onProgress != nil {
+				if percent, ok := parseBugreportzProgress(value); ok {
+					onProgress(percent)
+				}
+			}
+		case "OK":
+			remotePath = value
+		case "FAIL":
+			failure = value
+		}
+		return true
+	}, "bugreportz", "-p")
+	if err != nil {
+		return fmt.Errorf("adb bugreportz: %w", err)
+	}
+	if failure != "" {
+		return fmt.Errorf("adb bugreportz: %s", failure)
+	}
+	if remotePath == "" {
+		return errors.New("adb bugreportz: no report generated")
+	}
+	defer func() { _, _ = d.RunShellCommand("rm", "-f", remotePath) }()
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("adb bugreportz: %w", err)
+	}
+	return d.Pull(remotePath, dst)
+}
+
+// bugReportText writes the plain text report of bugreport to dst
+func (d Device) bugReportText(ctx context.Context, dst io.Writer) error {
+	// bugreport stays quiet while collecting some sections, ctx bounds it
+	r, err := d.WithReadTimeout(0).executeCommandStreaming("shell:bugreport")
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	stop := closeOnCancel(ctx, r)
+	defer stop()
+
+	_, err = io.Copy(dst, r)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("adb bugreport: %w", ctxErr)
+	}
+	if err != nil {
+		return fmt.Errorf("adb bugreport: %w", err)
+	}
+	return nil
+}
+
+// parseBugreportzLine splits a status line of bugreportz -p, e.g.
+// "PROGRESS:12/100", "OK:/bugreports/report.zip" or "FAIL:reason"
+func parseBugreportzLine(line string) (status, value string) {
+	kv := strings.SplitN(strings.TrimSpace(line), ":", 2)
+	if len(kv) != 2 {
+		return "", ""
+	}
+	return kv[0], kv[1]
+}
+
+// parseBugreportzProgress returns the percentage of a "<done>/<total>"
+// progress
+func parseBugreportzProgress(value string) (int, bool) {
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) != 2 {
+		return 0, false
+	}
+	done, err1 := strconv.Atoi(parts[0])
+	total, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || total <= 0 {
+		return 0, false
+	}
+	return done * 100 / total, true
+}
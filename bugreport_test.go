@@ -0,0 +1,100 @@
+package gadb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func Test_parseBugreportz(t *testing.T) {
+	tests := []struct {
+		line          string
+		status, value string
+	}{
+		{line: "BEGIN:/bugreports/report.zip", status: "BEGIN", value: "/bugreports/report.zip"},
+		{line: "PROGRESS:120/400\r", status: "PROGRESS", value: "120/400"},
+		{line: "OK:/bugreports/report.zip", status: "OK", value: "/bugreports/report.zip"},
+		{line: "FAIL:Could not open /bugreports", status: "FAIL", value: "Could not open /bugreports"},
+		{line: "noise"},
+	}
+	for _, tt := range tests {
+		status, value := parseBugreportzLine(tt.line)
+		if status != tt.status || value != tt.value {
+			t.Errorf("parseBugreportzLine(%q) = %q, %q, want %q, %q", tt.line, status, value, tt.status, tt.value)
+		}
+	}
+
+	if percent, ok := parseBugreportzProgress("120/400"); !ok || percent != 30 {
+		t.Errorf("parseBugreportzProgress() = %d, %v, want 30", percent, ok)
+	}
+	if _, ok := parseBugreportzProgress("120/0"); ok {
+		t.Error("expected invalid progress")
+	}
+}
+
+// shellServer returns a dialer to an in-memory adb server running shell
+// commands on a fake device with run, which returns their output
+func shellServer(t *testing.T, run func(cmd string) string) DialFunc {
+	return fakeServer(t, func(request string) string {
+		if strings.HasPrefix(request, "host:transport") {
+			return "OKAY"
+		}
+		cmd := strings.TrimPrefix(request, "shell:")
+		if cmd == request {
+			return fmt.Sprintf("FAIL%04xunknown", len("unknown"))
+		}
+		return "OKAY" + run(cmd)
+	})
+}
+
+func TestDevice_BugReport_detect(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		wantZip bool
+	}{
+		{name: "bugreportz", version: "1.1\n", wantZip: true},
+		{name: "not found", version: "/system/bin/sh: bugreportz: not found\n"},
+		{name: "empty", version: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var zip bool
+			d := Device{
+				adbClient: Client{readTimeout: defaultAdbReadTimeout, dial: shellServer(t, func(cmd string) string {
+					switch cmd {
+					case "bugreportz -v 2>&1":
+						return tt.version
+					case "bugreportz -p":
+						zip = true
+						return "PROGRESS:50/100\nFAIL:no space left\n"
+					case "bugreport":
+						return "== dumpstate ==\n"
+					}
+					return ""
+				})},
+				serial: "fake",
+			}
+
+			var buf bytes.Buffer
+			err := d.BugReport(context.Background(), &buf)
+			if zip != tt.wantZip {
+				t.Fatalf("bugreportz -p run = %v, want %v", zip, tt.wantZip)
+			}
+			if tt.wantZip {
+				if err == nil || !strings.Contains(err.Error(), "no space left") {
+					t.Errorf("BugReport() error = %v, want the bugreportz failure", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != "== dumpstate ==\n" {
+				t.Errorf("BugReport() wrote %q, want the plain text report", buf.String())
+			}
+		})
+	}
+}
@@ -1,11 +1,17 @@
 package gadb
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net"
+	"os"
 	"os/exec"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 const (
@@ -16,22 +22,138 @@ const (
 	AdbDaemonPort = 5555
 )
 
-// Client contains the information needed to communicate with the adb server
+// Client contains the information needed to communicate with the adb server.
+// A Client and the Devices it returns are safe for concurrent use by
+// multiple goroutines; every command runs on its own connection.
 type Client struct {
-	host string
-	port int
+	host          string
+	port          int
+	readTimeout   time.Duration
+	dialTimeout   time.Duration
+	dial          DialFunc
+	poolSize      int
+	pool          *connPool
+	syncChunkSize int
+	tls           *tlsDevices
+	tracer        Tracer
+	retryAttempts int
+	retryBackoff  time.Duration
+	resources     *clientResources
 }
 
+// ErrClientClosed is returned by the commands of a closed Client
+var ErrClientClosed = errors.New("adb client closed")
+
+// clientResources tracks the long-lived streams of a Client and its copies,
+// so Close can release them
+type clientResources struct {
+	mu      sync.Mutex
+	closed  bool
+	streams map[io.Closer]struct{}
+}
+
+func newClientResources() *clientResources {
+	return &clientResources{streams: map[io.Closer]struct{}{}}
+}
+
+// DialFunc dials a connection to the adb server
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// ClientOption configures a Client
+type ClientOption func(*Client)
+
+// WithDialer sets the function used to connect to the adb server, e.g. to go
+// through a proxy or a tunnel. Defaults to a net.Dialer.
+func WithDialer(dial DialFunc) ClientOption {
+	return func(c *Client) {
+		c.dial = dial
+	}
+}
+
+// WithReadTimeout sets the timeout of every read from the adb server,
+// a non positive timeout disables it. Defaults to 60 seconds.
+func WithReadTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.readTimeout = timeout
+	}
+}
+
+// WithDialTimeout sets the timeout of the connection to the adb server,
+// a non positive timeout disables it. Defaults to 10 seconds.
+func WithDialTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.dialTimeout = timeout
+	}
+}
+
+// WithPoolSize sets the number of connections to the adb server dialed
+// ahead of time, sparing the dial to the commands. The pool is disabled by
+// default, clients using one should be closed with Close.
+func WithPoolSize(size int) ClientOption {
+	return func(c *Client) {
+		c.poolSize = size
+	}
+}
+
+// Tracer is called after every adb command with the requested service, its
+// duration and its error. Only the service is reported, never the data
+// exchanged on it such as the content of pushed or pulled files.
+type Tracer func(cmd string, dur time.Duration, err error)
+
+// WithTracer sets the tracer called after every command of the client and
+// of its devices
+func WithTracer(tracer Tracer) ClientOption {
+	return func(c *Client) {
+		c.tracer = tracer
+	}
+}
+
+// trace reports the command started at start to the tracer, with the error
+// pointed by err, once the command is done
+func (c Client) trace(command string, start time.Time, err *error) {
+	trace(c.tracer, command, start, err)
+}
+
+func trace(tracer Tracer, command string, start time.Time, err *error) {
+	tracer(command, time.Since(start), *err)
+}
+
+// WithSyncChunkSize sets the size of the chunks of file data pushed to the
+// devices, bounded to the 64KB maximum of the protocol. Defaults to 64KB.
+func WithSyncChunkSize(size int) ClientOption {
+	return func(c *Client) {
+		if size > syncMaxChunkSize {
+			size = syncMaxChunkSize
+		}
+		c.syncChunkSize = size
+	}
+}
+
+const (
+	startServerAttempts = 5
+	startServerBackoff  = 100 * time.Millisecond
+)
+
 // StartServer will attempt to start the adb server
 func StartServer() error {
-	adb, err := exec.LookPath("adb")
+	return startServer("", AdbServerPort)
+}
+
+// startServer starts the adb server with the adb binary at adbPath, or found
+// in PATH if empty
+func startServer(adbPath string, port int) error {
+	if adbPath == "" {
+		adbPath = "adb"
+	}
+
+	adb, err := exec.LookPath(adbPath)
 	if err != nil {
-		return err
+		return fmt.Errorf("adb start-server: adb binary not found: %w", err)
 	}
 
-	err = exec.Command(adb, "-L", fmt.Sprintf("tcp:localhost:%d", AdbServerPort), "start-server").Run()
+	err = exec.Command(adb, "-L", fmt.Sprintf("tcp:localhost:%d", port), "start-server").Run()
 	if err != nil {
-		return err
+		return fmt.Errorf("adb start-server: %w", err)
 	}
 	return nil
 }
@@ -44,33 +166,188 @@ func (e ErrWarnings) Error() string {
 }
 
 // NewClient creates a new adb client
-func NewClient() (Client, error) {
-	return NewClientWithHost("localhost")
+func NewClient(opts ...ClientOption) (Client, error) {
+	return NewClientWithHost("localhost", opts...)
 }
 
 // NewClientWithHost creates a new adb client with the specified host
-func NewClientWithHost(host string) (Client, error) {
-	return NewClientWithHostAndPort(host, AdbServerPort)
+func NewClientWithHost(host string, opts ...ClientOption) (Client, error) {
+	return NewClientWithHostAndPort(host, AdbServerPort, opts...)
 }
 
 // NewClientWithHostAndPort creates a new adb client with the specified host and port
-func NewClientWithHostAndPort(host string, port int) (Client, error) {
+func NewClientWithHostAndPort(host string, port int, opts ...ClientOption) (Client, error) {
 	c := Client{
-		host: host,
-		port: port,
+		host:        host,
+		port:        port,
+		readTimeout: defaultAdbReadTimeout,
+		dialTimeout: defaultDialTimeout,
+		tls:         newTLSDevices(),
+		resources:   newClientResources(),
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.poolSize > 0 {
+		dial, addr, timeout := c.dial, c.address(), c.dialTimeout
+		c.pool = newConnPool(c.poolSize, func() (net.Conn, error) {
+			return dialServer(dial, addr, timeout)
+		})
 	}
 
 	// Validate that we can communicate with the client
 	tp, err := c.createTransport()
 	if err != nil {
-		return Client{}, err
+		return Client{}, fmt.Errorf("adb server %s unreachable: %w", c.address(), err)
 	}
 	tp.Close()
 
 	return c, nil
 }
 
-// Version returns the version of the adb server
+// NewClientFromEnv creates a new adb client for the server designated by
+// ANDROID_ADB_SERVER_HOST and ANDROID_ADB_SERVER_PORT, like the adb binary.
+// They default to localhost and 5037.
+func NewClientFromEnv(opts ...ClientOption) (Client, error) {
+	host, port, err := serverFromEnv()
+	if err != nil {
+		return Client{}, err
+	}
+	return NewClientWithHostAndPort(host, port, opts...)
+}
+
+func serverFromEnv() (host string, port int, err error) {
+	host, port = "localhost", AdbServerPort
+	if h := os.Getenv("ANDROID_ADB_SERVER_HOST"); h != "" {
+		host = h
+	}
+	if p := os.Getenv("ANDROID_ADB_SERVER_PORT"); p != "" {
+		port, err = strconv.Atoi(p)
+		if err != nil || port <= 0 || port > 65535 {
+			return "", 0, fmt.Errorf("invalid ANDROID_ADB_SERVER_PORT: %q", p)
+		}
+	}
+	return host, port, nil
+}
+
+// NewClientStartServer creates a new adb client, starting the local adb server
+// if it is not running. The server is started with the adb binary at adbPath,
+// or found in PATH if empty.
+func NewClientStartServer(adbPath string, opts ...ClientOption) (Client, error) {
+	c, err := NewClient(opts...)
+	if err == nil {
+		return c, nil
+	}
+
+	err = startServer(adbPath, AdbServerPort)
+	if err != nil {
+		return Client{}, err
+	}
+
+	backoff := startServerBackoff
+	for attempt := 1; ; attempt++ {
+		c, err = NewClient(opts...)
+		if err == nil || attempt == startServerAttempts {
+			return c, err
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// Close releases the resources of the client and of its copies: the idle
+// pooled connections, the tracking streams such as TrackDevices, and the
+// devices connected with ConnectTLS. Other commands in flight complete, but
+// new ones fail with ErrClientClosed. Close is idempotent.
+func (c Client) Close() error {
+	if c.resources == nil {
+		return nil
+	}
+
+	c.resources.mu.Lock()
+	if c.resources.closed {
+		c.resources.mu.Unlock()
+		return nil
+	}
+	c.resources.closed = true
+	streams := c.resources.streams
+	c.resources.streams = nil
+	c.resources.mu.Unlock()
+
+	var err error
+	keep := func(closeErr error) {
+		if err == nil {
+			err = closeErr
+		}
+	}
+
+	if c.pool != nil {
+		keep(c.pool.close())
+	}
+	for s := range streams {
+		keep(s.Close())
+	}
+
+	if c.tls != nil {
+		c.tls.mu.Lock()
+		for hostPort, d := range c.tls.devices {
+			keep(d.Close())
+			delete(c.tls.devices, hostPort)
+		}
+		c.tls.mu.Unlock()
+	}
+	return err
+}
+
+func (c Client) isClosed() bool {
+	if c.resources == nil {
+		return false
+	}
+	c.resources.mu.Lock()
+	defer c.resources.mu.Unlock()
+	return c.resources.closed
+}
+
+// track registers a long-lived stream to be closed by Close. The stream is
+// a map key and must be comparable, so transports pass their socket. The
+// returned untrack function must be called once the stream is closed.
+func (c Client) track(stream io.Closer) (untrack func(), err error) {
+	if c.resources == nil {
+		return func() {}, nil
+	}
+
+	c.resources.mu.Lock()
+	defer c.resources.mu.Unlock()
+	if c.resources.closed {
+		return nil, ErrClientClosed
+	}
+	c.resources.streams[stream] = struct{}{}
+
+	return func() {
+		c.resources.mu.Lock()
+		defer c.resources.mu.Unlock()
+		delete(c.resources.streams, stream)
+	}, nil
+}
+
+// ErrServerTooOld is returned when the adb server is older than a command
+// requires
+var ErrServerTooOld = errors.New("adb server too old")
+
+const (
+	// minSupportedServerVersion is the oldest adb server whose behavior the
+	// client is written against, that of adb 1.0.39. Older servers are
+	// reported to the tracer.
+	minSupportedServerVersion = 39
+	// featuresServerVersion is the first adb server answering the features
+	// requests, adb 1.0.32
+	featuresServerVersion = 32
+)
+
+// Version returns the version of the adb server. Versions older than the
+// client fully supports are reported to the tracer, if any, as a
+// host:version trace wrapping ErrServerTooOld.
 func (c Client) Version() (int, error) {
 	resp, err := c.executeCommand("host:version")
 	if err != nil {
@@ -82,9 +359,44 @@ func (c Client) Version() (int, error) {
 		return 0, err
 	}
 
+	if v < minSupportedServerVersion && c.tracer != nil {
+		c.tracer("host:version", 0, fmt.Errorf("warning: adb server version %d, %d or later is fully supported: %w", v, minSupportedServerVersion, ErrServerTooOld))
+	}
 	return int(v), nil
 }
 
+// RequireMinVersion returns an error wrapping ErrServerTooOld if the version
+// of the adb server is older than v
+func (c Client) RequireMinVersion(v int) error {
+	version, err := c.Version()
+	if err != nil {
+		return err
+	}
+	if version < v {
+		return fmt.Errorf("adb server version %d, %d required: %w", version, v, ErrServerTooOld)
+	}
+	return nil
+}
+
+// Features returns the features supported by the adb server, e.g. shell_v2, cmd, stat_v2
+func (c Client) Features() ([]string, error) {
+	resp, err := c.executeCommand("host:features")
+	if err != nil {
+		return nil, err
+	}
+	return parseFeatures(resp), nil
+}
+
+func parseFeatures(resp string) []string {
+	var features []string
+	for _, f := range strings.FieldsFunc(resp, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if f = strings.TrimSpace(f); f != "" {
+			features = append(features, f)
+		}
+	}
+	return features
+}
+
 // SerialList returns a list of serial numbers of all connected devices
 func (c Client) SerialList() ([]string, error) {
 	resp, err := c.executeCommand("host:devices")
@@ -134,7 +446,7 @@ func (c Client) List() ([]Device, error) {
 			key, val := split[0], split[1]
 			mapAttrs[key] = val
 		}
-		devices = append(devices, Device{adbClient: c, serial: fields[0], attrs: mapAttrs})
+		devices = append(devices, Device{adbClient: c, serial: fields[0], attrs: mapAttrs, features: &featureCache{}})
 	}
 
 	if len(warnings) > 0 {
@@ -143,6 +455,91 @@ func (c Client) List() ([]Device, error) {
 	return devices, nil
 }
 
+// Device returns the device with the given serial, with its attributes as
+// listed by List
+func (c Client) Device(serial string) (Device, error) {
+	devices, err := c.List()
+	if err != nil && len(devices) == 0 {
+		return Device{}, err
+	}
+
+	for _, d := range devices {
+		if d.serial == serial {
+			return d, nil
+		}
+	}
+	return Device{}, fmt.Errorf("adb device %s: %w", serial, ErrDeviceNotFound)
+}
+
+// DeviceUnchecked returns the device with the given serial without asking
+// the adb server, so it has no attributes and its existence is not checked
+func (c Client) DeviceUnchecked(serial string) Device {
+	return Device{adbClient: c, serial: serial, attrs: map[string]string{}, features: &featureCache{}}
+}
+
+// DeviceByTransportID returns the device connected with the transport id,
+// as reported by Device.TransportID. Commands sent to the returned device
+// select it by this id, which disambiguates devices sharing a serial.
+func (c Client) DeviceByTransportID(id string) (Device, error) {
+	devices, err := c.List()
+	if err != nil && len(devices) == 0 {
+		return Device{}, err
+	}
+
+	for _, d := range devices {
+		if tid, err := d.TransportID(); err == nil && tid == id {
+			d.selector = selectTransportID
+			return d, nil
+		}
+	}
+	return Device{}, fmt.Errorf("adb transport-id %s: %w", id, ErrDeviceNotFound)
+}
+
+// USBDevice returns the only device connected via USB, like adb -d.
+// Commands sent to the returned device are routed by the adb server to the
+// USB device.
+func (c Client) USBDevice() (Device, error) {
+	return c.onlyDevice("usb", selectUSB, func(d Device) bool {
+		usb, err := d.IsUsb()
+		return err == nil && usb
+	})
+}
+
+// LocalDevice returns the only device connected via TCP/IP or emulator,
+// like adb -e. Commands sent to the returned device are routed by the adb
+// server to the local device.
+func (c Client) LocalDevice() (Device, error) {
+	return c.onlyDevice("local", selectLocal, func(d Device) bool {
+		usb, err := d.IsUsb()
+		return err != nil || !usb
+	})
+}
+
+// onlyDevice returns the only listed device matching match
+func (c Client) onlyDevice(kind string, selector deviceSelector, match func(Device) bool) (Device, error) {
+	devices, err := c.List()
+	if err != nil && len(devices) == 0 {
+		return Device{}, err
+	}
+
+	var found []Device
+	for _, d := range devices {
+		if match(d) {
+			found = append(found, d)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return Device{}, fmt.Errorf("adb %s device: %w", kind, ErrDeviceNotFound)
+	case 1:
+		found[0].selector = selector
+		return found[0], nil
+	default:
+		return Device{}, fmt.Errorf("adb %s device: %w", kind, ErrMoreThanOneDevice)
+	}
+}
+
 // ForwardList returns a list of all forward connections
 func (c Client) ForwardList() ([]DeviceForward, error) {
 	resp, err := c.executeCommand("host:list-forward")
@@ -187,6 +584,48 @@ func (c Client) ConnectHostAndPort(ip string, port int) error {
 	return nil
 }
 
+// Pair pairs with a device for wireless debugging (Android 11+), using the
+// 6 digit pairing code and the host:port displayed by the device. The key of
+// the device is pinned by the first ConnectTLS to its host.
+func (c Client) Pair(hostPort, pairingCode string) error {
+	if !isPairingCode(pairingCode) {
+		return fmt.Errorf("adb pair: pairing code must be 6 digits: %q", pairingCode)
+	}
+
+	resp, err := c.executeCommand(fmt.Sprintf("host:pair:%s:%s", pairingCode, hostPort))
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case strings.HasPrefix(resp, "Successfully paired"):
+		if c.tls != nil {
+			c.tls.pairedHost(hostPort)
+		}
+		return nil
+	case strings.Contains(resp, "Wrong password"):
+		return fmt.Errorf("adb pair %s: %w", hostPort, ErrWrongPairingCode)
+	case strings.Contains(resp, "Unable to start pairing client"),
+		strings.Contains(resp, "Connection refused"),
+		strings.Contains(resp, "failed to connect"):
+		return fmt.Errorf("adb pair %s: %w: %s", hostPort, ErrHostUnreachable, resp)
+	default:
+		return fmt.Errorf("adb pair: %s", resp)
+	}
+}
+
+func isPairingCode(code string) bool {
+	if len(code) != 6 {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
 // DisconnectHost disconnects from a device via TCP/IP
 func (c Client) DisconnectHost(ip string) error {
 	return c.disconnect(ip)
@@ -222,15 +661,31 @@ func (c Client) DisconnectAll() error {
 	return nil
 }
 
+// ReconnectOffline kicks the devices in the offline state, forcing them to reconnect
+func (c Client) ReconnectOffline() error {
+	_, err := c.executeCommand("host:reconnect-offline")
+	if err != nil && !isDisconnect(err) {
+		return err
+	}
+	return nil
+}
+
 // KillServer kills the adb server
 func (c Client) KillServer() error {
-	tp, err := c.createTransport()
+	if c.isClosed() {
+		return ErrClientClosed
+	}
+	// Not from the pool, its connections die with the server
+	tp, err := newTransport(c.dial, c.address(), c.dialTimeout, c.readTimeout)
 	if err != nil {
 		return err
 	}
 	defer tp.Close()
 
 	err = tp.Send("host:kill")
+	if c.pool != nil {
+		_ = c.pool.reset()
+	}
 	if err != nil {
 		return err
 	}
@@ -238,34 +693,77 @@ func (c Client) KillServer() error {
 }
 
 func (c Client) createTransport() (tp transport, err error) {
-	return newTransport(net.JoinHostPort(c.host, fmt.Sprint(c.port)))
+	if c.isClosed() {
+		return transport{}, ErrClientClosed
+	}
+	if c.pool != nil {
+		sock, err := c.pool.get()
+		if err != nil {
+			return transport{}, err
+		}
+		return transport{sock: sock, readTimeout: c.readTimeout, syncChunkSize: c.syncChunkSize, tracer: c.tracer}, nil
+	}
+
+	tp, err = newTransport(c.dial, c.address(), c.dialTimeout, c.readTimeout)
+	tp.syncChunkSize = c.syncChunkSize
+	tp.tracer = c.tracer
+	return tp, err
 }
 
-func (c Client) executeCommand(command string) (string, error) {
+func (c Client) address() string {
+	return net.JoinHostPort(c.host, fmt.Sprint(c.port))
+}
+
+func (c Client) executeCommand(command string) (resp string, err error) {
+	if c.tracer != nil {
+		defer c.trace(command, time.Now(), &err)
+	}
+
+	backoff := c.retryBackoff
+	for attempt := 1; ; attempt++ {
+		var sent bool
+		resp, sent, err = c.executeCommandOnce(command)
+		if err == nil || attempt >= c.retryAttempts || !isTransient(err) ||
+			sent && !isIdempotent(command) {
+			return resp, err
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// executeCommandOnce also reports whether the command was sent, after which
+// the server may have acted on it even though the response was lost
+func (c Client) executeCommandOnce(command string) (string, bool, error) {
 	tp, err := c.createTransport()
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
 	defer tp.Close()
 
 	err = tp.Send(command)
 	if err != nil {
-		return "", err
+		return "", true, err
 	}
 
 	err = tp.VerifyResponse()
 	if err != nil {
-		return "", err
+		return "", true, err
 	}
 
 	resp, err := tp.UnpackString()
 	if err != nil {
-		return "", err
+		return "", true, err
 	}
-	return resp, nil
+	return resp, true, nil
 }
 
-func (c Client) executeCommandWithoutResponse(command string) error {
+func (c Client) executeCommandWithoutResponse(command string) (err error) {
+	if c.tracer != nil {
+		defer c.trace(command, time.Now(), &err)
+	}
+
 	tp, err := c.createTransport()
 	if err != nil {
 		return err
@@ -1,9 +1,30 @@
 package gadb
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 )
 
+func TestNewClientStartServer(t *testing.T) {
+	_, err := NewClientStartServer("/does/not/exist/adb", WithDialer(func(context.Context, string, string) (net.Conn, error) {
+		return nil, syscall.ECONNREFUSED
+	}))
+	if err == nil || !strings.Contains(err.Error(), "adb binary not found") {
+		t.Fatalf("expected adb binary not found error, got %v", err)
+	}
+}
+
 func TestClient_Version(t *testing.T) {
 	c, err := NewClient()
 	if err != nil {
@@ -18,6 +39,113 @@ func TestClient_Version(t *testing.T) {
 	t.Log(v)
 }
 
+func TestClient_Features(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	features, err := c.Features()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log(features)
+}
+
+func Test_parseFeatures(t *testing.T) {
+	got := parseFeatures("shell_v2,cmd,stat_v2\nabb,")
+	want := []string{"shell_v2", "cmd", "stat_v2", "abb"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseFeatures() = %q, want %q", got, want)
+	}
+}
+
+func TestClient_WithReadTimeout(t *testing.T) {
+	c, err := NewClient(WithReadTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.readTimeout != time.Second {
+		t.Fatalf("readTimeout = %v, want %v", c.readTimeout, time.Second)
+	}
+
+	tp, err := c.createTransport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tp.Close()
+
+	if tp.readTimeout != time.Second {
+		t.Fatalf("transport readTimeout = %v, want %v", tp.readTimeout, time.Second)
+	}
+}
+
+// fakeServer returns a dialer connecting to an in-memory adb server, which
+// answers each request with the response returned by handle. A request
+// switching to a device transport is followed by the service request on the
+// same connection. If stream is given, it is called with the connection
+// after the last response, to stream data or hold the connection open.
+func fakeServer(t *testing.T, handle func(request string) string, stream ...func(request string, conn net.Conn)) DialFunc {
+	return func(_ context.Context, _, _ string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go func() {
+			defer server.Close()
+
+			for {
+				length := make([]byte, 4)
+				if _, err := io.ReadFull(server, length); err != nil {
+					return
+				}
+				size, err := strconv.ParseInt(string(length), 16, 64)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				request := make([]byte, size)
+				if _, err := io.ReadFull(server, request); err != nil {
+					t.Error(err)
+					return
+				}
+				resp := handle(string(request))
+				if _, err := server.Write([]byte(resp)); err != nil {
+					return
+				}
+				if strings.HasPrefix(string(request), "host:transport") && resp == "OKAY" {
+					continue
+				}
+
+				for _, s := range stream {
+					s(string(request), server)
+				}
+				return
+			}
+		}()
+		return client, nil
+	}
+}
+
+func TestClient_WithDialer(t *testing.T) {
+	c, err := NewClientWithHost("fake", WithDialer(fakeServer(t, func(request string) string {
+		if request != "host:version" {
+			return "FAIL0007unknown"
+		}
+		return "OKAY00040029"
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := c.Version()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 41 {
+		t.Fatalf("Version() = %d, want 41", v)
+	}
+}
+
 func TestClient_SerialList(t *testing.T) {
 	c, err := NewClient()
 	if err != nil {
@@ -92,6 +220,34 @@ func TestClient_ConnectHost(t *testing.T) {
 	}
 }
 
+func TestClient_Pair(t *testing.T) {
+	t.Skip("Requires manual setup of a host to pair with")
+
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.Pair("192.168.1.28:37000", "123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_isPairingCode(t *testing.T) {
+	for code, want := range map[string]bool{
+		"123456":  true,
+		"12345":   false,
+		"1234567": false,
+		"12a456":  false,
+		"":        false,
+	} {
+		if got := isPairingCode(code); got != want {
+			t.Errorf("isPairingCode(%q) = %v, want %v", code, got, want)
+		}
+	}
+}
+
 func TestClient_DisconnectHost(t *testing.T) {
 	t.Skip("Requires manual setup of a host to connect to")
 
@@ -118,6 +274,18 @@ func TestClient_DisconnectAll(t *testing.T) {
 	}
 }
 
+func TestClient_ReconnectOffline(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.ReconnectOffline()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestClient_KillServer(t *testing.T) {
 	t.Skip("Killing server makes all other unit test fail, so skip it")
 
@@ -131,3 +299,343 @@ func TestClient_KillServer(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestServerFromEnv(t *testing.T) {
+	for _, name := range []string{"ANDROID_ADB_SERVER_HOST", "ANDROID_ADB_SERVER_PORT"} {
+		if old, ok := os.LookupEnv(name); ok {
+			defer os.Setenv(name, old)
+		} else {
+			defer os.Unsetenv(name)
+		}
+	}
+
+	for _, tt := range []struct {
+		host, port string
+		wantHost   string
+		wantPort   int
+		wantErr    bool
+	}{
+		{wantHost: "localhost", wantPort: AdbServerPort},
+		{host: "adb.example.com", port: "15037", wantHost: "adb.example.com", wantPort: 15037},
+		{port: "nope", wantErr: true},
+		{port: "70000", wantErr: true},
+	} {
+		os.Setenv("ANDROID_ADB_SERVER_HOST", tt.host)
+		os.Setenv("ANDROID_ADB_SERVER_PORT", tt.port)
+
+		host, port, err := serverFromEnv()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("serverFromEnv(%q, %q) error = %v", tt.host, tt.port, err)
+			continue
+		}
+		if host != tt.wantHost || port != tt.wantPort {
+			t.Errorf("serverFromEnv(%q, %q) = %s:%d, want %s:%d", tt.host, tt.port, host, port, tt.wantHost, tt.wantPort)
+		}
+	}
+}
+
+func TestWithTracer(t *testing.T) {
+	var traced []string
+	c, err := NewClientWithHost("fake", WithDialer(fakeServer(t, func(request string) string {
+		switch request {
+		case "host:version":
+			return "OKAY00040029"
+		case "host:transport:emulator-5554", "sync:":
+			return "OKAY"
+		}
+		return "FAIL0007unknown"
+	})), WithTracer(func(cmd string, dur time.Duration, err error) {
+		traced = append(traced, fmt.Sprintf("%s %v", cmd, err))
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Version(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Features(); err == nil {
+		t.Fatal("expected error")
+	}
+
+	tp, err := c.DeviceUnchecked("emulator-5554").createDeviceTransport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tp.Close()
+	if _, err := tp.CreateSyncTransport(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"host:version <nil>",
+		"host:features command failed: unknown",
+		"host:transport:emulator-5554 <nil>",
+		"sync: <nil>",
+	}
+	if !reflect.DeepEqual(traced, want) {
+		t.Errorf("traced %q, want %q", traced, want)
+	}
+}
+
+func TestClient_DeviceByTransportID(t *testing.T) {
+	devices := "emulator-5554 device product:sdk model:A device:generic transport_id:1\n" +
+		"emulator-5554 device product:sdk model:B device:generic transport_id:2\n"
+
+	c, err := NewClientWithHost("fake", WithDialer(fakeServer(t, func(request string) string {
+		switch request {
+		case "host:version":
+			return "OKAY00040029"
+		case "host:devices-l":
+			return fmt.Sprintf("OKAY%04x%s", len(devices), devices)
+		case "host-transport-id:2:get-state":
+			return "OKAY0006device"
+		default:
+			return "FAIL0007unknown"
+		}
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := c.DeviceByTransportID("2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model, _ := d.Model(); model != "B" {
+		t.Errorf("Model() = %q, want B", model)
+	}
+	if state, err := d.State(); err != nil || state != StateOnline {
+		t.Errorf("State() = %v, %v", state, err)
+	}
+
+	if _, err := c.DeviceByTransportID("3"); !errors.Is(err, ErrDeviceNotFound) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestClient_USBDevice(t *testing.T) {
+	devices := "0123456789ABCDEF device usb:1-1 product:p model:Phone device:d transport_id:1\n" +
+		"192.168.1.2:5555 device product:p model:Tablet device:d transport_id:2\n" +
+		"emulator-5554 device product:sdk model:Emulator device:generic transport_id:3\n"
+
+	c, err := NewClientWithHost("fake", WithDialer(fakeServer(t, func(request string) string {
+		switch request {
+		case "host:version":
+			return "OKAY00040029"
+		case "host:devices-l":
+			return fmt.Sprintf("OKAY%04x%s", len(devices), devices)
+		case "host-usb:get-state":
+			return "OKAY0006device"
+		default:
+			return "FAIL0007unknown"
+		}
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := c.USBDevice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Serial() != "0123456789ABCDEF" {
+		t.Errorf("Serial() = %q", d.Serial())
+	}
+	if state, err := d.State(); err != nil || state != StateOnline {
+		t.Errorf("State() = %v, %v", state, err)
+	}
+
+	if _, err := c.LocalDevice(); !errors.Is(err, ErrMoreThanOneDevice) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestClient_Device(t *testing.T) {
+	devices := "emulator-5554 device product:sdk model:Emulator device:generic transport_id:3\n"
+
+	c, err := NewClientWithHost("fake", WithDialer(fakeServer(t, func(request string) string {
+		switch request {
+		case "host:version":
+			return "OKAY00040029"
+		case "host:devices-l":
+			return fmt.Sprintf("OKAY%04x%s", len(devices), devices)
+		default:
+			return "FAIL0007unknown"
+		}
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := c.Device("emulator-5554")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := d.TransportID(); id != "3" {
+		t.Errorf("TransportID() = %q, want 3", id)
+	}
+
+	if _, err := c.Device("emulator-5556"); !errors.Is(err, ErrDeviceNotFound) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if d := c.DeviceUnchecked("emulator-5556"); d.Serial() != "emulator-5556" {
+		t.Errorf("Serial() = %q", d.Serial())
+	}
+}
+
+func TestClient_Close(t *testing.T) {
+	c, err := NewClientWithHost("fake", WithDialer(trackServer(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := c.TrackDevices(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("second Close() = %v", err)
+	}
+
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("unexpected event")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("TrackDevices stream not closed by Close")
+	}
+
+	if _, err := c.Version(); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Version() after Close = %v, want ErrClientClosed", err)
+	}
+}
+
+// trackServer returns a dialer connecting to an in-memory adb server
+// answering host:version, and holding host:track-devices streams open
+// without any device until the client closes them
+func trackServer(t *testing.T) DialFunc {
+	return fakeServer(t, func(request string) string {
+		if request != "host:track-devices" {
+			return "OKAY00040029"
+		}
+		return "OKAY0000"
+	}, func(request string, conn net.Conn) {
+		if request == "host:track-devices" {
+			_, _ = io.Copy(ioutil.Discard, conn)
+		}
+	})
+}
+
+func TestWithDialTimeout(t *testing.T) {
+	hang := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	start := time.Now()
+	_, err := NewClientWithHost("fake", WithDialer(hang), WithPoolSize(0), WithDialTimeout(50*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("NewClientWithHost() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("dial took %v", d)
+	}
+}
+
+func TestNewClient_unreachable(t *testing.T) {
+	refuse := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: syscall.ECONNREFUSED}
+	}
+
+	_, err := NewClientWithHostAndPort("adb.example", 5038, WithDialer(refuse), WithPoolSize(0))
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Errorf("NewClientWithHostAndPort() error = %v, want %v", err, syscall.ECONNREFUSED)
+	}
+	if err == nil || strings.Count(err.Error(), "adb.example:5038") != 1 {
+		t.Errorf("NewClientWithHostAndPort() error = %v, want the server address once", err)
+	}
+}
+
+func TestClient_RequireMinVersion(t *testing.T) {
+	var warnings []error
+	c, err := NewClientWithHost("fake", WithDialer(fakeServer(t, func(request string) string {
+		if request != "host:version" {
+			return "FAIL001eunknown host service: features"
+		}
+		return "OKAY0004001f"
+	})), WithTracer(func(cmd string, dur time.Duration, err error) {
+		if errors.Is(err, ErrServerTooOld) {
+			warnings = append(warnings, err)
+		}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.RequireMinVersion(31); err != nil {
+		t.Errorf("RequireMinVersion(31) = %v", err)
+	}
+	if err := c.RequireMinVersion(41); !errors.Is(err, ErrServerTooOld) {
+		t.Errorf("RequireMinVersion(41) = %v, want %v", err, ErrServerTooOld)
+	}
+	if len(warnings) != 2 {
+		t.Errorf("traced %d warnings, want 2", len(warnings))
+	}
+
+	features, err := c.DeviceUnchecked("emulator-5554").Features()
+	if err != nil || len(features) != 0 {
+		t.Errorf("Features() = %q, %v, want none", features, err)
+	}
+}
+
+func TestDevice_supportsFeature_cached(t *testing.T) {
+	requests := 0
+	c, err := NewClientWithHost("fake", WithDialer(fakeServer(t, func(request string) string {
+		if request == "host-serial:emulator-5554:features" {
+			requests++
+			return "OKAY0010shell_v2,stat_v2"
+		}
+		return "OKAY00040029"
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := c.DeviceUnchecked("emulator-5554")
+	for i := 0; i < 3; i++ {
+		if !d.supportsFeature("stat_v2") || d.supportsFeature("ls_v2") {
+			t.Fatal("unexpected features")
+		}
+	}
+	if requests != 1 {
+		t.Errorf("features requested %d times, want 1", requests)
+	}
+}
+
+func TestDevice_Features_failure(t *testing.T) {
+	versions := 0
+	c, err := NewClientWithHost("fake", WithDialer(fakeServer(t, func(request string) string {
+		if request == "host:version" {
+			versions++
+			return "OKAY0004001f"
+		}
+		return "FAIL0020device 'emulator-5556' not found"
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.DeviceUnchecked("emulator-5556").Features(); err == nil {
+		t.Error("Features() succeeded for a missing device")
+	}
+	if versions != 0 {
+		t.Errorf("host:version requested %d times, want 0", versions)
+	}
+}
@@ -1,12 +1,19 @@
 package gadb
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -23,12 +30,20 @@ const (
 	StateOnline       DeviceState = "online"
 	StateOffline      DeviceState = "offline"
 	StateDisconnected DeviceState = "disconnected"
+	StateUnauthorized DeviceState = "unauthorized"
+	StateRecovery     DeviceState = "recovery"
+	StateBootloader   DeviceState = "bootloader"
+	StateSideload     DeviceState = "sideload"
 )
 
 var deviceStateStrings = map[string]DeviceState{
-	"":        StateDisconnected,
-	"offline": StateOffline,
-	"device":  StateOnline,
+	"":             StateDisconnected,
+	"offline":      StateOffline,
+	"device":       StateOnline,
+	"unauthorized": StateUnauthorized,
+	"recovery":     StateRecovery,
+	"bootloader":   StateBootloader,
+	"sideload":     StateSideload,
 }
 
 func deviceStateConv(k string) DeviceState {
@@ -39,6 +54,18 @@ func deviceStateConv(k string) DeviceState {
 	return deviceState
 }
 
+// RebootMode is the mode the device reboots into
+type RebootMode string
+
+// List of RebootModes
+const (
+	RebootSystem     RebootMode = ""
+	RebootBootloader RebootMode = "bootloader"
+	RebootRecovery   RebootMode = "recovery"
+	RebootSideload   RebootMode = "sideload"
+	RebootFastboot   RebootMode = "fastboot"
+)
+
 // DeviceForward is the forward information of a device
 type DeviceForward struct {
 	Serial string
@@ -53,8 +80,34 @@ type Device struct {
 	adbClient Client
 	serial    string
 	attrs     map[string]string
+	direct    *directConn
+	selector  deviceSelector
+	// features caches the features of the device for the sync requests,
+	// shared by the copies of the device
+	features *featureCache
 }
 
+// featureCache holds the features of a device once fetched
+type featureCache struct {
+	mu       sync.Mutex
+	features []string
+	fetched  bool
+}
+
+// deviceSelector is the way the adb server is told which device to use
+type deviceSelector int
+
+const (
+	selectSerial deviceSelector = iota
+	// selectTransportID selects the device by its transport id, the serial
+	// may be shared by several devices
+	selectTransportID
+	// selectUSB selects the only USB device, like adb -d
+	selectUSB
+	// selectLocal selects the only TCP/IP device or emulator, like adb -e
+	selectLocal
+)
+
 // Product returns the product name of the device
 func (d Device) Product() (string, error) {
 	if d.HasAttribute("product") {
@@ -84,13 +137,31 @@ func (d Device) HasAttribute(key string) bool {
 	return ok
 }
 
-func (d Device) transportId() (string, error) {
+// TransportID returns the id the adb server gave to the connection of the
+// device. Unlike the serial, it is unique, but changes on reconnection.
+func (d Device) TransportID() (string, error) {
 	if d.HasAttribute("transport_id") {
 		return d.attrs["transport_id"], nil
 	}
 	return "", errors.New("does not have attribute: transport_id")
 }
 
+// WithReadTimeout returns a copy of the device using the given read timeout,
+// e.g. for long running commands. A non positive timeout disables it.
+func (d Device) WithReadTimeout(timeout time.Duration) Device {
+	d.adbClient.readTimeout = timeout
+	return d
+}
+
+// Close closes the connection of a device connected with ConnectDirect,
+// it is a no-op for devices connected through the adb server
+func (d Device) Close() error {
+	if d.direct == nil {
+		return nil
+	}
+	return d.direct.Close()
+}
+
 // DeviceInfo returns the information of the device
 func (d Device) DeviceInfo() map[string]string {
 	return d.attrs
@@ -113,7 +184,7 @@ func (d Device) IsUsb() (bool, error) {
 
 // State returns the state of the device
 func (d Device) State() (DeviceState, error) {
-	resp, err := d.adbClient.executeCommand(fmt.Sprintf("host-serial:%s:get-state", d.serial))
+	resp, err := d.adbClient.executeCommand(d.hostCommand("get-state"))
 	if err != nil {
 		return StateUnknown, err
 	}
@@ -122,23 +193,112 @@ func (d Device) State() (DeviceState, error) {
 
 // DevicePath returns the path of the device
 func (d Device) DevicePath() (string, error) {
-	resp, err := d.adbClient.executeCommand(fmt.Sprintf("host-serial:%s:get-devpath", d.serial))
+	resp, err := d.adbClient.executeCommand(d.hostCommand("get-devpath"))
 	if err != nil {
 		return "", err
 	}
 	return resp, nil
 }
 
+// Features returns the features supported by both the device and the adb server
+func (d Device) Features() ([]string, error) {
+	if d.direct != nil {
+		return parseFeatures(d.attrs["features"]), nil
+	}
+
+	resp, err := d.adbClient.executeCommand(d.hostCommand("features"))
+	if err != nil {
+		// Servers predating the features request support none of them,
+		// callers fall back to the legacy services
+		var adbErr *AdbError
+		if errors.As(err, &adbErr) && strings.Contains(adbErr.Message, "unknown host service") &&
+			errors.Is(d.adbClient.RequireMinVersion(featuresServerVersion), ErrServerTooOld) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return parseFeatures(resp), nil
+}
+
+// HasFeature returns true if the feature is supported by the device
+func (d Device) HasFeature(name string) (bool, error) {
+	features, err := d.Features()
+	if err != nil {
+		return false, err
+	}
+
+	for _, f := range features {
+		if f == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // Forward forwards a local port to a remote port on the device
 func (d Device) Forward(localPort, remotePort int, noRebind ...bool) error {
-	command := ""
-	local := fmt.Sprintf("tcp:%d", localPort)
-	remote := fmt.Sprintf("tcp:%d", remotePort)
+	return d.ForwardSpec(fmt.Sprintf("tcp:%d", localPort), fmt.Sprintf("tcp:%d", remotePort), noRebind...)
+}
+
+// ForwardToLocalAbstract forwards a local TCP port to the abstract unix
+// socket name on the device, as used by scrcpy or minicap. name is sent
+// verbatim, without the leading @ shown by /proc/net/unix.
+func (d Device) ForwardToLocalAbstract(localPort int, name string) error {
+	if name == "" || strings.ContainsAny(name, ";\x00") {
+		return fmt.Errorf("adb forward: invalid abstract socket name %q", name)
+	}
+	return d.ForwardSpec(fmt.Sprintf("tcp:%d", localPort), "localabstract:"+name)
+}
+
+// ForwardToFreePort forwards a local TCP port chosen by the adb server to a
+// remote TCP port on the device, and returns the local port. This avoids
+// racing with other programs for a free port.
+func (d Device) ForwardToFreePort(remotePort int) (localPort int, err error) {
+	command := d.hostCommand(fmt.Sprintf("forward:tcp:0;tcp:%d", remotePort))
+	if d.adbClient.tracer != nil {
+		defer d.adbClient.trace(command, time.Now(), &err)
+	}
+
+	tp, err := d.adbClient.createTransport()
+	if err != nil {
+		return 0, err
+	}
+	defer tp.Close()
+
+	err = tp.Send(command)
+	if err != nil {
+		return 0, err
+	}
 
+	// The first OKAY acknowledges the request, the second the forward,
+	// followed by the resolved port
+	for i := 0; i < 2; i++ {
+		err = tp.VerifyResponse()
+		if err != nil {
+			return 0, err
+		}
+	}
+	resp, err := tp.UnpackString()
+	if err != nil {
+		return 0, err
+	}
+
+	localPort, err = strconv.Atoi(strings.TrimSpace(resp))
+	if err != nil {
+		return 0, fmt.Errorf("adb forward: invalid port %q", resp)
+	}
+	return localPort, nil
+}
+
+// ForwardSpec forwards a local socket to a remote socket on the device. Both
+// arguments are adb socket specs such as "tcp:8080", "localabstract:minicap",
+// "localreserved:name", "localfilesystem:/path", "jdwp:<pid>" or "dev:/dev/ttyS0".
+func (d Device) ForwardSpec(local, remote string, noRebind ...bool) error {
+	command := ""
 	if len(noRebind) != 0 && noRebind[0] {
-		command = fmt.Sprintf("host-serial:%s:forward:norebind:%s;%s", d.serial, local, remote)
+		command = d.hostCommand(fmt.Sprintf("forward:norebind:%s;%s", local, remote))
 	} else {
-		command = fmt.Sprintf("host-serial:%s:forward:%s;%s", d.serial, local, remote)
+		command = d.hostCommand(fmt.Sprintf("forward:%s;%s", local, remote))
 	}
 
 	return d.adbClient.executeCommandWithoutResponse(command)
@@ -163,15 +323,79 @@ func (d Device) ForwardList() ([]DeviceForward, error) {
 // ForwardKill kills a forward on the device
 func (d Device) ForwardKill(localPort int) error {
 	return d.adbClient.executeCommandWithoutResponse(
-		fmt.Sprintf("host-serial:%s:killforward:%s:%d",
-			d.serial,
+		d.hostCommand(fmt.Sprintf("killforward:%s:%d",
 			"tcp",
 			localPort,
-		),
+		)),
 	)
 }
 
-// RunShellCommand runs a shell command on the device
+// Reverse reverses a remote socket on the device to a local socket on the host,
+// e.g. Reverse("tcp:8080", "tcp:8080")
+func (d Device) Reverse(remote, local string) error {
+	_, err := d.executeReverseCommand(fmt.Sprintf("reverse:forward:%s;%s", remote, local), false)
+	return err
+}
+
+// ReverseList returns the list of reverse connections on the device
+func (d Device) ReverseList() ([]DeviceForward, error) {
+	resp, err := d.executeReverseCommand("reverse:list-forward", true)
+	if err != nil {
+		return nil, err
+	}
+
+	var reverseList []DeviceForward
+	for _, l := range strings.Split(resp, "\n") {
+		fields := strings.Fields(l)
+		if len(fields) < 3 {
+			continue
+		}
+		reverseList = append(reverseList, DeviceForward{Serial: d.serial, Remote: fields[1], Local: fields[2]})
+	}
+	return reverseList, nil
+}
+
+// ReverseKill kills a reverse connection on the device
+func (d Device) ReverseKill(remote string) error {
+	_, err := d.executeReverseCommand("reverse:killforward:"+remote, false)
+	return err
+}
+
+// ReverseKillAll kills all reverse connections on the device
+func (d Device) ReverseKillAll() error {
+	_, err := d.executeReverseCommand("reverse:killforward-all", false)
+	return err
+}
+
+// executeReverseCommand sends a reverse command over a device transport. Unlike
+// the host-serial forward commands, adbd answers with a second OKAY as the
+// status of the request, except for list-forward which answers with a string.
+func (d Device) executeReverseCommand(command string, withResponse bool) (string, error) {
+	tp, err := d.createDeviceTransport()
+	if err != nil {
+		return "", err
+	}
+	defer tp.Close()
+
+	err = tp.Send(command)
+	if err != nil {
+		return "", err
+	}
+
+	err = tp.VerifyResponse()
+	if err != nil {
+		return "", err
+	}
+
+	if withResponse {
+		return tp.UnpackString()
+	}
+	return "", tp.VerifyResponse()
+}
+
+// RunShellCommand runs a shell command on the device. args are quoted so
+// they reach the command verbatim, use RunShellCommandRaw for pipes and
+// redirections.
 func (d Device) RunShellCommand(cmd string, args ...string) (string, error) {
 	b, err := d.RunShellCommandStreaming(cmd, args...)
 	if err != nil {
@@ -180,9 +404,23 @@ func (d Device) RunShellCommand(cmd string, args ...string) (string, error) {
 	return string(b), nil
 }
 
+// RunShellCommandRaw runs the command line as is on the device shell,
+// allowing pipes, redirections and variable expansion
+func (d Device) RunShellCommandRaw(cmdLine string) (string, error) {
+	if strings.TrimSpace(cmdLine) == "" {
+		return "", errors.New("adb shell: command cannot be empty")
+	}
+
+	raw, err := d.executeCommand(fmt.Sprintf("shell:%s", cmdLine))
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
+
 // RunShellCommandStreaming runs a shell command on the device and returns the output
 func (d Device) RunShellCommandStreaming(cmd string, args ...string) ([]byte, error) {
-	cmd = fmt.Sprintf("%s %s", cmd, strings.Join(args, " "))
+	cmd = shellCommand(cmd, args)
 	if strings.TrimSpace(cmd) == "" {
 		return nil, errors.New("adb shell: command cannot be empty")
 	}
@@ -194,6 +432,108 @@ func (d Device) RunShellCommandStreaming(cmd string, args ...string) ([]byte, er
 	return raw, nil
 }
 
+// RunShellCommandContext runs a shell command on the device, aborting it when ctx is done
+func (d Device) RunShellCommandContext(ctx context.Context, cmd string, args ...string) (string, error) {
+	cmd = shellCommand(cmd, args)
+	if strings.TrimSpace(cmd) == "" {
+		return "", errors.New("adb shell: command cannot be empty")
+	}
+	if err := ctx.Err(); err != nil {
+		return "", fmt.Errorf("adb shell: %w", err)
+	}
+
+	r, err := d.executeCommandStreaming(fmt.Sprintf("shell:%s", cmd))
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	stop := closeOnCancel(ctx, r)
+	defer stop()
+
+	b, err := io.ReadAll(r)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return string(b), fmt.Errorf("adb shell: %w", ctxErr)
+	}
+	if err != nil {
+		return string(b), fmt.Errorf("failed to read cmd response: %w", err)
+	}
+	return string(b), nil
+}
+
+// RunShellCommandLines runs a shell command on the device, calling onLine
+// with each line of its output, without the line terminator, as it is
+// received. It stops early once onLine returns false or ctx is done, so
+// long-running commands such as logcat or top can be followed without
+// buffering their whole output.
+func (d Device) RunShellCommandLines(ctx context.Context, onLine func(string) bool, cmd string, args ...string) error {
+	cmd = shellCommand(cmd, args)
+	if strings.TrimSpace(cmd) == "" {
+		return errors.New("adb shell: command cannot be empty")
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("adb shell: %w", err)
+	}
+
+	// The command may stay quiet for long, ctx bounds it instead
+	r, err := d.WithReadTimeout(0).executeCommandStreaming(fmt.Sprintf("shell:%s", cmd))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	stop := closeOnCancel(ctx, r)
+	defer stop()
+
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" && !onLine(strings.TrimRight(line, "\r\n")) {
+			return nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return fmt.Errorf("adb shell: %w", ctxErr)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read cmd response: %w", err)
+		}
+	}
+}
+
+// Exec runs a command on the device with the exec: service and returns its output.
+// Unlike shell:, exec: does not allocate a PTY, so no newline translation
+// happens and binary output (screencap, tar, ...) is returned intact. stderr
+// is not captured.
+func (d Device) Exec(cmd string, args ...string) ([]byte, error) {
+	r, err := d.ExecStreaming(cmd, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cmd response: %w", err)
+	}
+	return b, nil
+}
+
+// ExecStreaming runs a command on the device with the exec: service and returns
+// its output as a stream, which must be closed by the caller.
+// Unlike shell:, exec: does not allocate a PTY, so no newline translation
+// happens and binary output (screencap, tar, ...) is returned intact.
+func (d Device) ExecStreaming(cmd string, args ...string) (io.ReadCloser, error) {
+	cmd = shellCommand(cmd, args)
+	if strings.TrimSpace(cmd) == "" {
+		return nil, errors.New("adb exec: command cannot be empty")
+	}
+
+	return d.executeCommandStreaming(fmt.Sprintf("exec:%s", cmd))
+}
+
 // EnableAdbOverTCP enables adb over tcp
 func (d Device) EnableAdbOverTCP(port ...int) error {
 	if len(port) == 0 {
@@ -209,13 +549,193 @@ func (d Device) EnableAdbOverTCP(port ...int) error {
 	return nil
 }
 
-func (d Device) createDeviceTransport() (transport, error) {
-	tp, err := newTransport(fmt.Sprintf("%s:%d", d.adbClient.host, d.adbClient.port))
+// GoWireless enables adb over tcp on AdbDaemonPort and returns the address
+// of the device on its Wi-Fi network, ready for Client.ConnectHostAndPort
+func (d Device) GoWireless() (hostPort string, err error) {
+	ip, err := d.wlanIP()
+	if err != nil {
+		return "", err
+	}
+
+	err = d.EnableAdbOverTCP(AdbDaemonPort)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(ip, strconv.Itoa(AdbDaemonPort)), nil
+}
+
+// wlanIP returns the IPv4 address of the Wi-Fi interface of the device
+func (d Device) wlanIP() (string, error) {
+	addrs, err := d.IPAddresses()
+	if err != nil {
+		return "", err
+	}
+	if ip, ok := addrs["wlan0"]; ok {
+		return ip, nil
+	}
+	return "", errors.New("adb wlan0: no IPv4 address, is Wi-Fi connected?")
+}
+
+// Reboot reboots the device into the given mode
+func (d Device) Reboot(mode RebootMode) error {
+	_, err := d.executeCommandUntilDisconnect("reboot:" + string(mode))
+	return err
+}
+
+// Reconnect kicks the device, forcing it to reconnect, e.g. when it is stuck
+// offline. The device transport drops, see Client.WaitForDevice.
+func (d Device) Reconnect() error {
+	resp, err := d.adbClient.executeCommand(d.hostCommand("reconnect"))
+	if err != nil && !isDisconnect(err) {
+		return err
+	}
+
+	if resp != "" && !strings.HasPrefix(resp, "reconnecting") {
+		return fmt.Errorf("adb reconnect: %s", resp)
+	}
+	return nil
+}
+
+// ErrRootNotAllowed is returned when adbd cannot run as root on production builds
+var ErrRootNotAllowed = errors.New("adbd cannot run as root in production builds")
+
+// Root restarts adbd with root permissions. adbd restarts, dropping the
+// transport, so callers usually need to wait for the device to come back
+// online before issuing further commands.
+func (d Device) Root() error {
+	resp, err := d.executeCommandUntilDisconnect("root:")
+	if err != nil {
+		return err
+	}
+
+	resp = strings.TrimSpace(resp)
+	switch {
+	case strings.Contains(resp, "cannot run as root"):
+		return ErrRootNotAllowed
+	case strings.HasPrefix(resp, "restarting adbd as root"),
+		strings.HasPrefix(resp, "adbd is already running as root"):
+		return nil
+	default:
+		return fmt.Errorf("adb root: %s", resp)
+	}
+}
+
+// Unroot restarts adbd without root permissions. adbd restarts, dropping the
+// transport, so callers usually need to wait for the device to come back
+// online before issuing further commands.
+func (d Device) Unroot() error {
+	resp, err := d.executeCommandUntilDisconnect("unroot:")
+	if err != nil {
+		return err
+	}
+
+	resp = strings.TrimSpace(resp)
+	switch {
+	case strings.HasPrefix(resp, "restarting adbd as non root"),
+		strings.HasPrefix(resp, "adbd not running as root"):
+		return nil
+	default:
+		return fmt.Errorf("adb unroot: %s", resp)
+	}
+}
+
+// RemountError is returned when the partitions could not be remounted
+type RemountError struct {
+	// Reason is the output of adbd, e.g. explaining that verity is enabled
+	Reason string
+}
+
+func (e *RemountError) Error() string {
+	return "adb remount: " + e.Reason
+}
+
+// Remount remounts the system partitions read-write. It requires a
+// userdebug or eng build and adbd running as root, see Root.
+func (d Device) Remount() error {
+	resp, err := d.executeCommand("remount:")
+	if err != nil {
+		return err
+	}
+
+	output := strings.TrimSpace(string(resp))
+	if strings.Contains(output, "remount succeeded") {
+		return nil
+	}
+	return &RemountError{Reason: output}
+}
+
+// SetVerity enables or disables dm-verity on the device, which must be
+// rebooted for the change to take effect. Verity must be disabled before
+// remounting the system partitions read-write.
+func (d Device) SetVerity(enabled bool) error {
+	service := "disable-verity"
+	if enabled {
+		service = "enable-verity"
+	}
+
+	resp, err := d.executeCommand(service + ":")
+	if err != nil {
+		return err
+	}
+
+	output := strings.TrimSpace(string(resp))
+	if strings.Contains(output, "Now reboot your device") ||
+		strings.Contains(output, "already enabled") ||
+		strings.Contains(output, "already disabled") {
+		return nil
+	}
+	return fmt.Errorf("adb %s: %s", service, output)
+}
+
+// hostCommand returns the adb server request running command for the device
+func (d Device) hostCommand(command string) string {
+	switch d.selector {
+	case selectTransportID:
+		return fmt.Sprintf("host-transport-id:%s:%s", d.attrs["transport_id"], command)
+	case selectUSB:
+		return "host-usb:" + command
+	case selectLocal:
+		return "host-local:" + command
+	default:
+		return fmt.Sprintf("host-serial:%s:%s", d.serial, command)
+	}
+}
+
+// transportCommand returns the adb server request switching the connection
+// to the device
+func (d Device) transportCommand() string {
+	switch d.selector {
+	case selectTransportID:
+		return fmt.Sprintf("host:transport-id:%s", d.attrs["transport_id"])
+	case selectUSB:
+		return "host:transport-usb"
+	case selectLocal:
+		return "host:transport-local"
+	default:
+		return fmt.Sprintf("host:transport:%s", d.serial)
+	}
+}
+
+func (d Device) createDeviceTransport() (tp transport, err error) {
+	if d.direct != nil {
+		s, err := d.direct.newStream()
+		if err != nil {
+			return transport{}, fmt.Errorf("failed to create transport: %w", err)
+		}
+		return transport{sock: s, readTimeout: d.adbClient.readTimeout, syncChunkSize: d.adbClient.syncChunkSize, tracer: d.adbClient.tracer}, nil
+	}
+
+	command := d.transportCommand()
+	if d.adbClient.tracer != nil {
+		defer d.adbClient.trace(command, time.Now(), &err)
+	}
+
+	tp, err = d.adbClient.createTransport()
 	if err != nil {
 		return transport{}, fmt.Errorf("failed to create transport: %w", err)
 	}
 
-	err = tp.Send(fmt.Sprintf("host:transport:%s", d.serial))
+	err = tp.Send(command)
 	if err != nil {
 		return transport{}, fmt.Errorf("failed to send transport command: %w", err)
 	}
@@ -241,7 +761,11 @@ func (d Device) executeCommand(command string) ([]byte, error) {
 	return b, nil
 }
 
-func (d Device) executeCommandStreaming(command string, onlyVerifyResponse ...bool) (resp io.ReadCloser, err error) {
+func (d Device) executeCommandStreaming(command string, onlyVerifyResponse ...bool) (resp io.ReadWriteCloser, err error) {
+	if d.adbClient.tracer != nil {
+		// Streams are only traced until the service is opened
+		defer d.adbClient.trace(command, time.Now(), &err)
+	}
 	if len(onlyVerifyResponse) == 0 {
 		onlyVerifyResponse = []bool{false}
 	}
@@ -271,10 +795,26 @@ func (d Device) executeCommandStreaming(command string, onlyVerifyResponse ...bo
 		return nil, nil
 	}
 
-	resp = tp.sock
+	resp = timeoutConn{Conn: tp.sock, readTimeout: tp.readTimeout}
 	return
 }
 
+// executeCommandUntilDisconnect runs a command after which adbd drops the
+// connection (e.g. reboot or root), so the disconnect is not an error.
+func (d Device) executeCommandUntilDisconnect(command string) (string, error) {
+	r, err := d.executeCommandStreaming(command)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	b, err := io.ReadAll(r)
+	if err != nil && !isDisconnect(err) {
+		return string(b), fmt.Errorf("failed to read cmd response: %w", err)
+	}
+	return string(b), nil
+}
+
 // List returns the list of files in the directory
 func (d Device) List(remotePath string) ([]os.FileInfo, error) {
 	tp, err := d.createDeviceTransport()
@@ -289,14 +829,20 @@ func (d Device) List(remotePath string) ([]os.FileInfo, error) {
 	}
 	defer sync.Close()
 
-	err = sync.Send("LIST", remotePath)
+	v2 := d.supportsFeature("ls_v2")
+	command, readEntry := "LIST", sync.ReadDirectoryEntry
+	if v2 {
+		command, readEntry = "LIS2", sync.ReadDirectoryEntryV2
+	}
+
+	err = sync.Send(command, remotePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send list command: %w", err)
 	}
 
 	var devFileInfos []os.FileInfo
 	for {
-		entry, ok, err := sync.ReadDirectoryEntry()
+		entry, ok, err := readEntry()
 		if err != nil {
 			return nil, fmt.Errorf("failed to read directory entry: %w", err)
 		}
@@ -310,6 +856,85 @@ func (d Device) List(remotePath string) ([]os.FileInfo, error) {
 	return devFileInfos, nil
 }
 
+// Stat returns the file information of the remote path. The returned error
+// wraps os.ErrNotExist if the path does not exist. On devices supporting the
+// sync v2 protocol, Sys() returns a *FileStat.
+func (d Device) Stat(remotePath string) (os.FileInfo, error) {
+	tp, err := d.createDeviceTransport()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create device transport: %w", err)
+	}
+	defer tp.Close()
+
+	sync, err := tp.CreateSyncTransport()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sync transport: %w", err)
+	}
+	defer sync.Close()
+
+	entry, err := sync.Stat(remotePath, d.supportsFeature("stat_v2"))
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Exists reports whether the remote path exists. An error is only returned
+// when the path could not be checked. Without stat_v2, paths in directories
+// the shell cannot read are reported missing.
+func (d Device) Exists(remotePath string) (bool, error) {
+	_, err := d.Stat(remotePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// IsDir reports whether the remote path is a directory, false if it does not
+// exist. Symbolic links are only followed on devices supporting stat_v2.
+func (d Device) IsDir(remotePath string) (bool, error) {
+	info, err := d.Stat(remotePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return info.IsDir(), nil
+}
+
+// supportsFeature returns true if the device is known to support the
+// feature. The features are only fetched once per device.
+func (d Device) supportsFeature(name string) bool {
+	features, err := d.cachedFeatures()
+	if err != nil {
+		return false
+	}
+	for _, f := range features {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (d Device) cachedFeatures() ([]string, error) {
+	if d.features == nil {
+		return d.Features()
+	}
+
+	d.features.mu.Lock()
+	defer d.features.mu.Unlock()
+	if !d.features.fetched {
+		features, err := d.Features()
+		if err != nil {
+			return nil, err
+		}
+		d.features.features, d.features.fetched = features, true
+	}
+	return d.features.features, nil
+}
+
 // FileWithStat represents a reader that also can call Stat() on
 type FileWithStat interface {
 	Stat() (os.FileInfo, error)
@@ -329,8 +954,72 @@ func (d Device) PushFile(local FileWithStat, remotePath string, modification ...
 	return d.Push(local, remotePath, modification[0], defaultFileMode)
 }
 
+// PushFilePreserve pushes a file to the device, keeping the modification
+// time and the permissions of the local file
+func (d Device) PushFilePreserve(local FileWithStat, remotePath string) error {
+	stat, err := local.Stat()
+	if err != nil {
+		return err
+	}
+	if !stat.Mode().IsRegular() {
+		return fmt.Errorf("adb push %s: not a regular file", stat.Name())
+	}
+
+	return d.Push(local, remotePath, stat.ModTime(), stat.Mode())
+}
+
 // Push pushes a file to the device
 func (d Device) Push(source io.Reader, remotePath string, modification time.Time, mode ...os.FileMode) error {
+	return d.PushWithProgress(source, remotePath, -1, nil, modification, mode...)
+}
+
+// ErrChecksumMismatch is returned by PushVerified when the pushed file differs
+// from the source
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// PushVerified pushes a file to the device, then compares the MD5 of the
+// remote file, computed by md5sum on the device, to the one of source
+func (d Device) PushVerified(source io.ReadSeeker, remotePath string, modification time.Time, mode ...os.FileMode) error {
+	_, err := source.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	err = d.Push(source, remotePath, modification, mode...)
+	if err != nil {
+		return err
+	}
+
+	_, err = source.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	h := md5.New()
+	_, err = io.Copy(h, source)
+	if err != nil {
+		return err
+	}
+	local := hex.EncodeToString(h.Sum(nil))
+
+	output, err := d.RunShellCommand("md5sum", remotePath)
+	if err != nil {
+		return fmt.Errorf("adb md5sum %s: %w", remotePath, err)
+	}
+	fields := strings.Fields(output)
+	if len(fields) == 0 || len(fields[0]) != len(local) {
+		return fmt.Errorf("adb md5sum %s: %s", remotePath, strings.TrimSpace(output))
+	}
+	if fields[0] != local {
+		return fmt.Errorf("adb push %s: %w: local %s, remote %s", remotePath, ErrChecksumMismatch, local, fields[0])
+	}
+	return nil
+}
+
+// PushWithProgress pushes a file to the device, calling onProgress with the
+// cumulative number of bytes sent after each chunk. size is the expected
+// number of bytes, letting the caller compute a percentage; when it is not
+// negative the push fails if the source yields a different amount.
+// A nil onProgress behaves exactly like Push.
+func (d Device) PushWithProgress(source io.Reader, remotePath string, size int64, onProgress func(sent int64), modification time.Time, mode ...os.FileMode) error {
 	if len(mode) == 0 {
 		mode = []os.FileMode{defaultFileMode}
 	}
@@ -347,16 +1036,19 @@ func (d Device) Push(source io.Reader, remotePath string, modification time.Time
 	}
 	defer sync.Close()
 
-	data := fmt.Sprintf("%s,%d", remotePath, mode[0])
+	data := fmt.Sprintf("%s,%d", remotePath, posixMode(mode[0]))
 	err = sync.Send("SEND", data)
 	if err != nil {
 		return err
 	}
 
-	err = sync.SendStream(source)
+	sent, err := sync.SendStream(source, onProgress)
 	if err != nil {
 		return err
 	}
+	if size >= 0 && sent != size {
+		return fmt.Errorf("adb push: sent %d bytes, expected %d", sent, size)
+	}
 
 	err = sync.SendStatus("DONE", uint32(modification.Unix()))
 	if err != nil {
@@ -372,6 +1064,25 @@ func (d Device) Push(source io.Reader, remotePath string, modification time.Time
 
 // Pull pulls a file from the device
 func (d Device) Pull(remotePath string, dest io.Writer) error {
+	return d.PullWithProgress(remotePath, dest, nil)
+}
+
+// PullWithProgress pulls a file from the device, calling onProgress with the
+// cumulative number of bytes received after each chunk. total is the size of
+// the remote file as reported by STAT, or -1 if it could not be determined.
+// A nil onProgress behaves exactly like Pull.
+func (d Device) PullWithProgress(remotePath string, dest io.Writer, onProgress func(received, total int64)) error {
+	var progress func(int64)
+	if onProgress != nil {
+		// The progress is optional, a failed STAT leaves the size unknown.
+		// It has its own sync session, which a failure may leave unusable.
+		total := int64(-1)
+		if info, err := d.Stat(remotePath); err == nil {
+			total = info.Size()
+		}
+		progress = func(received int64) { onProgress(received, total) }
+	}
+
 	tp, err := d.createDeviceTransport()
 	if err != nil {
 		return err
@@ -389,35 +1100,166 @@ func (d Device) Pull(remotePath string, dest io.Writer) error {
 		return err
 	}
 
-	err = sync.WriteStream(dest)
+	err = sync.WriteStream(dest, progress)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (d Device) Logcat(dst io.Writer, exitChan chan bool) error {
-	var tp transport
-	var err error
-	if tp, err = d.createDeviceTransport(); err != nil {
+// PullFilePreserve pulls a file from the device to localPath, keeping the
+// modification time and the permissions of the remote file
+func (d Device) PullFilePreserve(remotePath, localPath string) error {
+	info, err := d.Stat(remotePath)
+	if err != nil {
+		return err
+	}
+	if !info.(fileInfo).isRegular() {
+		return fmt.Errorf("adb pull %s: not a regular file", remotePath)
+	}
+
+	f, err := os.OpenFile(localPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = d.Pull(remotePath, f)
+	if err != nil {
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	// The umask applies on creation, and the file may already exist
+	err = os.Chmod(localPath, fileMode(uint32(info.Mode())))
+	if err != nil {
+		return err
+	}
+	return os.Chtimes(localPath, info.ModTime(), info.ModTime())
+}
+
+// resumeBlockSize is the dd block size used by PullResume, the partial
+// local file is cut back to a multiple of it before resuming
+const resumeBlockSize = 64 * 1024
+
+// PullResume pulls a file from the device to localPath, fetching only the
+// bytes missing from an existing partial copy when dd is available on the
+// device. A local file of the same size as the remote one is considered
+// complete, anything that cannot be resumed is pulled again from scratch
+func (d Device) PullResume(remotePath, localPath string) error {
+	info, err := d.Stat(remotePath)
+	if err != nil {
 		return err
 	}
-	defer func() { _ = tp.Close() }()
+	if !info.(fileInfo).isRegular() {
+		return fmt.Errorf("adb pull %s: not a regular file", remotePath)
+	}
+	size := info.Size()
+
+	if local, err := os.Stat(localPath); err == nil && local.Mode().IsRegular() && local.Size() <= size {
+		if local.Size() == size {
+			return nil
+		}
+		offset := local.Size() / resumeBlockSize * resumeBlockSize
+		if offset > 0 {
+			resumed, err := d.pullTail(remotePath, localPath, offset, size)
+			if err != nil {
+				return err
+			}
+			if resumed {
+				return nil
+			}
+		}
+	}
 
-	if err = tp.Send("shell:logcat"); err != nil {
+	f, err := os.OpenFile(localPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
 		return err
 	}
-	if err = tp.VerifyResponse(); err != nil {
+	defer f.Close()
+
+	err = d.Pull(remotePath, f)
+	if err != nil {
 		return err
 	}
+	return f.Close()
+}
+
+// pullTail appends the remote file from offset on to localPath with dd and
+// reports whether the missing size-offset bytes were all received. Transport
+// errors are returned as is so the partial file survives for the next attempt
+func (d Device) pullTail(remotePath, localPath string, offset, size int64) (bool, error) {
+	// exec: merges stderr into the stream, dd reports its record counts there
+	dd := shellCommand("dd", []string{"if=" + remotePath,
+		fmt.Sprintf("bs=%d", resumeBlockSize), fmt.Sprintf("skip=%d", offset/resumeBlockSize)})
+	r, err := d.ExecStreaming(dd + " 2>/dev/null")
+	if err != nil {
+		// Devices without exec: refuse the service, pull everything instead
+		var adbErr *AdbError
+		if errors.As(err, &adbErr) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer r.Close()
+
+	f, err := os.OpenFile(localPath, os.O_WRONLY, 0)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	err = f.Truncate(offset)
+	if err != nil {
+		return false, err
+	}
+	_, err = f.Seek(offset, io.SeekStart)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := io.CopyN(f, r, size-offset)
+	if err != nil && err != io.EOF {
+		return false, fmt.Errorf("adb pull %s: %w", remotePath, err)
+	}
+	err = f.Close()
+	if err != nil {
+		return false, err
+	}
+	return n == size-offset, nil
+}
+
+// ReadFile pulls a file from the device and returns its content
+func (d Device) ReadFile(remotePath string) ([]byte, error) {
+	var buf bytes.Buffer
+	err := d.Pull(remotePath, &buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteFile pushes data to a file on the device with the given mode,
+// modified now
+func (d Device) WriteFile(remotePath string, data []byte, mode os.FileMode) error {
+	return d.Push(bytes.NewReader(data), remotePath, time.Now(), mode)
+}
+
+// Logcat streams the device logs to dst until a value is received on exitChan
+//
+// Deprecated: use LogcatContext
+func (d Device) Logcat(dst io.Writer, exitChan chan bool) error {
 	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
 	go func() {
-		r := NewReader(ctx, tp.sock)
-		io.Copy(dst, r)
+		errCh <- d.LogcatContext(ctx, dst)
 	}()
 	<-exitChan
 	cancel()
-	return err
+	return <-errCh
 }
 
 func (d Device) Logcat2File(file string, exitChan chan bool) error {
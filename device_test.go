@@ -2,8 +2,15 @@ package gadb
 
 import (
 	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -148,6 +155,33 @@ func TestDevice_Forward(t *testing.T) {
 	}
 }
 
+func TestDevice_ForwardSpec(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	localPort := 61001
+	err = devices[0].ForwardSpec(fmt.Sprintf("tcp:%d", localPort), "localabstract:minicap")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = devices[0].ForwardKill(localPort)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestDevice_ForwardList(t *testing.T) {
 	c, err := NewClient()
 	if err != nil {
@@ -191,6 +225,38 @@ func TestDevice_ForwardKill(t *testing.T) {
 	}
 }
 
+func TestDevice_Reverse(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	err = devices[0].Reverse("tcp:61000", "tcp:6790")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reverseList, err := devices[0].ReverseList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(devices[0].serial, "->", reverseList)
+
+	err = devices[0].ReverseKill("tcp:61000")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestDevice_RunShellCommand(t *testing.T) {
 	c, err := NewClient()
 	if err != nil {
@@ -230,6 +296,54 @@ func TestDevice_RunShellCommand(t *testing.T) {
 
 }
 
+func TestDevice_RunShellCommandContext(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err = devices[0].RunShellCommandContext(ctx, "sleep", "10")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestDevice_Exec(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	output, err := devices[0].Exec("printf", `a\nb`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(output) != "a\nb" {
+		t.Fatalf("Exec() = %q, want %q", output, "a\nb")
+	}
+}
+
 func TestDevice_EnableAdbOverTCP(t *testing.T) {
 	c, err := NewClient()
 	if err != nil {
@@ -251,6 +365,55 @@ func TestDevice_EnableAdbOverTCP(t *testing.T) {
 	}
 }
 
+func TestDevice_Reboot(t *testing.T) {
+	t.Skip("Rebooting the device makes all other unit test fail, so skip it")
+
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	err = devices[0].Reboot(RebootSystem)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDevice_Root(t *testing.T) {
+	t.Skip("Restarting adbd makes all other unit test fail, so skip it")
+
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	err = devices[0].Root()
+	if errors.Is(err, ErrRootNotAllowed) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestDevice_List(t *testing.T) {
 	c, err := NewClient()
 	if err != nil {
@@ -276,6 +439,33 @@ func TestDevice_List(t *testing.T) {
 	}
 }
 
+func TestDevice_Stat(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	info, err := devices[0].Stat("/sdcard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(info.Name(), info.Mode(), info.Size(), info.ModTime(), info.IsDir())
+
+	_, err = devices[0].Stat("/sdcard/does-not-exist")
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected os.ErrNotExist, got %v", err)
+	}
+}
+
 func TestDevice_Push(t *testing.T) {
 	c, err := NewClient()
 	if err != nil {
@@ -315,6 +505,65 @@ func TestDevice_Push(t *testing.T) {
 	}
 }
 
+func TestDevice_PushMode(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	remotePath := "/data/local/tmp/gadb-mode.sh"
+	err = devices[0].Push(strings.NewReader("#!/bin/sh\n"), remotePath, time.Now(), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := devices[0].Stat(remotePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o755 {
+		t.Fatalf("mode = %o, want %o", perm, 0o755)
+	}
+}
+
+func TestDevice_PushWithProgress(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	data := bytes.Repeat([]byte("gadb"), 100*1024)
+	var last int64
+	err = devices[0].PushWithProgress(bytes.NewReader(data), "/sdcard/Download/progress.bin", int64(len(data)), func(sent int64) {
+		t.Logf("%d/%d", sent, len(data))
+		last = sent
+	}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last != int64(len(data)) {
+		t.Fatalf("last progress = %d, want %d", last, len(data))
+	}
+}
+
 func TestDevice_Pull(t *testing.T) {
 	c, err := NewClient()
 	if err != nil {
@@ -342,3 +591,395 @@ func TestDevice_Pull(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestDevice_PullWithProgress(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	buffer := bytes.NewBufferString("")
+	err = devices[0].PullWithProgress("/sdcard/Download/hello.txt", buffer, func(received, total int64) {
+		t.Logf("%d/%d", received, total)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDevice_PullWithProgress_statFailure(t *testing.T) {
+	// The STAT is answered with a FAIL, which leaves its session unusable
+	sync := func(request string, conn net.Conn) {
+		if request != "sync:" {
+			return
+		}
+		header := make([]byte, 8)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		path := make([]byte, binary.LittleEndian.Uint32(header[4:]))
+		if _, err := io.ReadFull(conn, path); err != nil {
+			return
+		}
+
+		var resp bytes.Buffer
+		switch string(header[:4]) {
+		case "RECV":
+			resp.WriteString("DATA")
+			binary.Write(&resp, binary.LittleEndian, uint32(5))
+			resp.WriteString("hello")
+			resp.WriteString("DONE")
+			binary.Write(&resp, binary.LittleEndian, uint32(0))
+		default:
+			resp.WriteString("FAIL")
+			binary.Write(&resp, binary.LittleEndian, uint32(6))
+			resp.WriteString("denied")
+		}
+		conn.Write(resp.Bytes())
+	}
+	d := Device{adbClient: Client{readTimeout: defaultAdbReadTimeout, dial: fakeServer(t, func(request string) string {
+		switch {
+		case strings.HasSuffix(request, ":features"):
+			return "OKAY0000"
+		case strings.HasPrefix(request, "host:transport"), request == "sync:":
+			return "OKAY"
+		}
+		return "FAIL0007unknown"
+	}, sync)}, serial: "fake"}
+
+	var buffer bytes.Buffer
+	var totals []int64
+	err := d.PullWithProgress("/sdcard/hello.txt", &buffer, func(received, total int64) {
+		totals = append(totals, total)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buffer.String() != "hello" {
+		t.Errorf("pulled %q, want %q", buffer.String(), "hello")
+	}
+	if len(totals) == 0 {
+		t.Error("onProgress not called")
+	}
+	for _, total := range totals {
+		if total != -1 {
+			t.Errorf("total = %d, want -1", total)
+		}
+	}
+}
+
+func TestDevice_WriteFile_ReadFile(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	remotePath := "/sdcard/Download/gadb-readfile.txt"
+	err = devices[0].WriteFile(remotePath, []byte("hello world"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devices[0].RunShellCommand("rm", remotePath)
+
+	data, err := devices[0].ReadFile(remotePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello world" {
+		t.Errorf("ReadFile() = %q, want %q", data, "hello world")
+	}
+}
+
+func TestDevice_PushFilePreserve(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	dir := t.TempDir()
+	localPath := filepath.Join(dir, "script.sh")
+	if err := ioutil.WriteFile(localPath, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(localPath, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	remotePath := "/data/local/tmp/gadb-preserve.sh"
+	if err := devices[0].PushFilePreserve(f, remotePath); err != nil {
+		t.Fatal(err)
+	}
+	defer devices[0].RunShellCommand("rm", remotePath)
+
+	pulledPath := filepath.Join(dir, "pulled.sh")
+	if err := devices[0].PullFilePreserve(remotePath, pulledPath); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(pulledPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o755 {
+		t.Errorf("mode = %v, want 0755", info.Mode().Perm())
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("mtime = %v, want %v", info.ModTime(), mtime)
+	}
+}
+
+func TestDevice_PullFilePreserve_setuid(t *testing.T) {
+	d := Device{adbClient: Client{readTimeout: defaultAdbReadTimeout, dial: syncServer(t, map[string]fakeFile{
+		"/system/bin/su": {mode: 0o104755, data: "#!/bin/sh\n"},
+		"/sdcard":        {mode: 0o040771},
+	}, func(string) string { return "" })}, serial: "fake"}
+
+	localPath := filepath.Join(t.TempDir(), "su")
+	if err := d.PullFilePreserve("/system/bin/su", localPath); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(localPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := os.ModeSetuid | 0o755; info.Mode() != want {
+		t.Errorf("mode = %v, want %v", info.Mode(), want)
+	}
+
+	err = d.PullFilePreserve("/sdcard", filepath.Join(t.TempDir(), "sdcard"))
+	if err == nil || !strings.Contains(err.Error(), "not a regular file") {
+		t.Errorf("PullFilePreserve() of a directory = %v, want not a regular file", err)
+	}
+}
+
+func TestDevice_PullResume(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	data := bytes.Repeat([]byte("0123456789abcdef"), 3*resumeBlockSize/16+100)
+	remotePath := "/data/local/tmp/gadb-resume.bin"
+	if err := devices[0].WriteFile(remotePath, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	defer devices[0].RunShellCommand("rm", remotePath)
+
+	// The first block differs from the remote file, a resume keeps it while a
+	// full pull would overwrite it
+	localPath := filepath.Join(t.TempDir(), "resume.bin")
+	partial := append(bytes.Repeat([]byte("x"), resumeBlockSize), "garbage"...)
+	if err := ioutil.WriteFile(localPath, partial, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := devices[0].PullResume(remotePath, localPath); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ioutil.ReadFile(localPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append(bytes.Repeat([]byte("x"), resumeBlockSize), data[resumeBlockSize:]...)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("pulled %d bytes, want the first block kept and the %d bytes after it", len(got), len(data)-resumeBlockSize)
+	}
+}
+
+func TestDevice_PullResume_notRegular(t *testing.T) {
+	var cmds []string
+	d := Device{adbClient: Client{readTimeout: defaultAdbReadTimeout, dial: syncServer(t, map[string]fakeFile{
+		"/sdcard/dir":  {mode: 0o040771},
+		"/sdcard/fifo": {mode: 0o010644},
+	}, func(cmd string) string {
+		cmds = append(cmds, cmd)
+		return ""
+	})}, serial: "fake"}
+
+	for _, remotePath := range []string{"/sdcard/dir", "/sdcard/fifo"} {
+		err := d.PullResume(remotePath, filepath.Join(t.TempDir(), "pulled"))
+		if err == nil || !strings.Contains(err.Error(), "not a regular file") {
+			t.Errorf("PullResume(%q) = %v, want not a regular file", remotePath, err)
+		}
+	}
+	if len(cmds) != 0 {
+		t.Errorf("ran %q, want nothing", cmds)
+	}
+}
+
+func TestDevice_RunShellCommandLines(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	var lines []string
+	err = devices[0].RunShellCommandLines(context.Background(), func(line string) bool {
+		lines = append(lines, line)
+		return len(lines) < 3
+	}, "seq", "1", "100000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(lines, ",") != "1,2,3" {
+		t.Errorf("lines = %q, want 1 to 3", lines)
+	}
+}
+
+func TestDevice_PushVerified(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	remotePath := "/data/local/tmp/gadb-verified.bin"
+	data := bytes.Repeat([]byte("gadb"), 100000)
+	err = devices[0].PushVerified(bytes.NewReader(data), remotePath, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	devices[0].RunShellCommand("rm", remotePath)
+}
+
+func TestDevice_ForwardToFreePort(t *testing.T) {
+	c, err := NewClientWithHost("fake", WithDialer(fakeServer(t, func(request string) string {
+		switch request {
+		case "host:version":
+			return "OKAY00040029"
+		case "host-serial:emulator-5554:forward:tcp:0;tcp:8080":
+			return "OKAYOKAY000545678"
+		}
+		return "FAIL0007unknown"
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	port, err := c.DeviceUnchecked("emulator-5554").ForwardToFreePort(8080)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if port != 45678 {
+		t.Errorf("ForwardToFreePort() = %d, want 45678", port)
+	}
+}
+
+func TestDevice_Exists_IsDir(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+	d := devices[0]
+
+	tests := []struct {
+		path          string
+		exists, isDir bool
+	}{
+		{path: "/data/local/tmp", exists: true, isDir: true},
+		{path: "/system/build.prop", exists: true},
+		{path: "/data/local/tmp/gadb-missing"},
+	}
+	for _, tt := range tests {
+		exists, err := d.Exists(tt.path)
+		if err != nil || exists != tt.exists {
+			t.Errorf("Exists(%s) = %v, %v, want %v", tt.path, exists, err, tt.exists)
+		}
+		isDir, err := d.IsDir(tt.path)
+		if err != nil || isDir != tt.isDir {
+			t.Errorf("IsDir(%s) = %v, %v, want %v", tt.path, isDir, err, tt.isDir)
+		}
+	}
+}
+
+func TestDevice_ForwardToLocalAbstract(t *testing.T) {
+	c, err := NewClientWithHost("fake", WithDialer(fakeServer(t, func(request string) string {
+		switch request {
+		case "host:version":
+			return "OKAY00040029"
+		case "host-serial:emulator-5554:forward:tcp:27183;localabstract:scrcpy_0a1b%2c":
+			return "OKAYOKAY"
+		}
+		return "FAIL0007unknown"
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := c.DeviceUnchecked("emulator-5554")
+
+	if err := d.ForwardToLocalAbstract(27183, "scrcpy_0a1b%2c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.ForwardToLocalAbstract(27183, "a;b"); err == nil {
+		t.Error("expected error for a name with a separator")
+	}
+}
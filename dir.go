@@ -0,0 +1,151 @@
+package gadb
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// PushDir pushes the local directory tree to remoteDir on the device,
+// preserving the relative layout and the permission bits of every file.
+// remoteDir is created if it does not exist. Symbolic links are followed.
+func (d Device) PushDir(localDir, remoteDir string) error {
+	return d.pushDir(localDir, remoteDir, map[string]bool{})
+}
+
+func (d Device) pushDir(localDir, remoteDir string, visited map[string]bool) error {
+	realDir, err := filepath.EvalSymlinks(localDir)
+	if err != nil {
+		return err
+	}
+	if visited[realDir] {
+		return fmt.Errorf("adb push: symlink loop at %s", localDir)
+	}
+	visited[realDir] = true
+	defer delete(visited, realDir)
+
+	return filepath.Walk(realDir, func(localPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(realDir, localPath)
+		if err != nil {
+			return err
+		}
+		remotePath := path.Join(remoteDir, filepath.ToSlash(rel))
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			info, err = os.Stat(localPath)
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return d.pushDir(localPath, remotePath, visited)
+			}
+		}
+
+		switch {
+		case info.IsDir():
+			return d.mkdirAll(remotePath)
+		case info.Mode().IsRegular():
+			return d.pushLocalFile(localPath, remotePath, info)
+		default:
+			// Sockets, devices and pipes cannot be pushed
+			return nil
+		}
+	})
+}
+
+func (d Device) pushLocalFile(localPath, remotePath string, info os.FileInfo) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = d.Push(f, remotePath, info.ModTime(), info.Mode().Perm())
+	if err != nil {
+		return fmt.Errorf("adb push %s: %w", localPath, err)
+	}
+	return nil
+}
+
+func (d Device) mkdirAll(remoteDir string) error {
+	output, err := d.RunShellCommand("mkdir", "-p", remoteDir)
+	if err != nil {
+		return fmt.Errorf("adb mkdir %s: %w", remoteDir, err)
+	}
+	if output = strings.TrimSpace(output); output != "" {
+		return fmt.Errorf("adb mkdir %s: %s", remoteDir, output)
+	}
+	return nil
+}
+
+// PullDir pulls the remote directory tree to localDir, creating local
+// directories as needed. Entries that are neither regular files nor
+// directories are skipped and reported as ErrWarnings.
+func (d Device) PullDir(remoteDir, localDir string) error {
+	var warnings []string
+	err := d.pullDir(remoteDir, localDir, &warnings)
+	if err != nil {
+		return err
+	}
+
+	if len(warnings) > 0 {
+		return ErrWarnings(warnings)
+	}
+	return nil
+}
+
+func (d Device) pullDir(remoteDir, localDir string, warnings *[]string) error {
+	err := os.MkdirAll(localDir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	// LIST does not recurse, so each subdirectory is listed on its own
+	entries, err := d.List(remoteDir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == "." || name == ".." {
+			continue
+		}
+
+		remotePath := path.Join(remoteDir, name)
+		localPath := filepath.Join(localDir, name)
+
+		switch {
+		case entry.IsDir():
+			err = d.pullDir(remotePath, localPath, warnings)
+		case entry.(fileInfo).isRegular():
+			err = d.pullLocalFile(remotePath, localPath, entry)
+		default:
+			*warnings = append(*warnings, fmt.Sprintf("skipping special file: %s", remotePath))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d Device) pullLocalFile(remotePath, localPath string, info os.FileInfo) error {
+	f, err := os.OpenFile(localPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = d.Pull(remotePath, f)
+	if err != nil {
+		return fmt.Errorf("adb pull %s: %w", remotePath, err)
+	}
+	return f.Close()
+}
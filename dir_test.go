@@ -0,0 +1,69 @@
+package gadb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDevice_PushDir(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	localDir := t.TempDir()
+	err = os.MkdirAll(filepath.Join(localDir, "a", "b"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(localDir, "a", "b", "hello.txt"), []byte("hello"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Symlink(filepath.Join(localDir, "a", "b", "hello.txt"), filepath.Join(localDir, "link.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = devices[0].PushDir(localDir, "/sdcard/Download/gadb-pushdir")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = devices[0].Stat("/sdcard/Download/gadb-pushdir/a/b/hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDevice_PullDir(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	localDir := t.TempDir()
+	err = devices[0].PullDir("/sdcard/Download", localDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,593 @@
+package gadb
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ADB wire protocol commands, see adb's protocol.txt
+const (
+	cmdSYNC = 0x434e5953
+	cmdCNXN = 0x4e584e43
+	cmdOPEN = 0x4e45504f
+	cmdOKAY = 0x59414b4f
+	cmdCLSE = 0x45534c43
+	cmdWRTE = 0x45545257
+	cmdAUTH = 0x48545541
+	cmdSTLS = 0x534c5453
+)
+
+// Arguments of the AUTH command
+const (
+	authToken        = 1
+	authSignature    = 2
+	authRSAPublicKey = 3
+)
+
+const (
+	adbVersion    = 0x01000001
+	adbMaxPayload = 256 * 1024
+	// adbPayloadLimit is the MAX_PAYLOAD of adbd, no message is larger
+	adbPayloadLimit   = 1024 * 1024
+	adbHeaderSize     = 24
+	adbHostFeatures   = "shell_v2,cmd,stat_v2,ls_v2,fixed_push_mkdir"
+	directDialTimeout = 10 * time.Second
+)
+
+// ErrDirectClosed is returned when using a closed direct connection
+var ErrDirectClosed = errors.New("adb direct: connection closed")
+
+type adbMessage struct {
+	command uint32
+	arg0    uint32
+	arg1    uint32
+	data    []byte
+}
+
+// directConn is a connection speaking the ADB wire protocol to adbd,
+// multiplexing the streams opened on it
+type directConn struct {
+	conn       net.Conn
+	maxPayload int
+	banner     string
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	streams map[uint32]*directStream
+	lastID  uint32
+	err     error
+}
+
+// ConnectDirect connects to adbd at addr (host:port) without going through
+// the adb server. key authenticates the host; if adbd does not know it yet,
+// its public key is sent and the connection must be accepted on the device.
+// Only device services are available on the returned Device, host-serial
+// commands such as State or Forward require the adb server.
+func ConnectDirect(addr string, key *rsa.PrivateKey) (*Device, error) {
+	conn, err := net.DialTimeout("tcp", addr, directDialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("adb direct %s: %w", addr, err)
+	}
+
+	dc, err := newDirectConn(conn, key, nil, defaultAdbReadTimeout)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("adb direct %s: %w", addr, err)
+	}
+
+	return &Device{
+		adbClient: Client{readTimeout: defaultAdbReadTimeout},
+		serial:    addr,
+		attrs:     parseBanner(dc.banner),
+		direct:    dc,
+	}, nil
+}
+
+// newDirectConn performs the handshake on conn, upgrading it to TLS with
+// tlsConfig if adbd asks for it. Each handshake message must arrive within
+// readTimeout, if positive.
+func newDirectConn(conn net.Conn, key *rsa.PrivateKey, tlsConfig *tls.Config, readTimeout time.Duration) (*directConn, error) {
+	if key.N.BitLen() != adbKeyBits {
+		return nil, fmt.Errorf("auth key must be %d bits, got %d", adbKeyBits, key.N.BitLen())
+	}
+
+	dc := &directConn{
+		conn:       conn,
+		maxPayload: adbMaxPayload,
+		streams:    map[uint32]*directStream{},
+	}
+
+	err := dc.handshake(key, tlsConfig, readTimeout)
+	if err != nil {
+		return nil, err
+	}
+	// The connection stays quiet while no stream is open, the streams have
+	// their own deadlines
+	err = dc.conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	go dc.readLoop()
+	return dc, nil
+}
+
+func (dc *directConn) handshake(key *rsa.PrivateKey, tlsConfig *tls.Config, readTimeout time.Duration) error {
+	err := dc.writeMessage(cmdCNXN, adbVersion, adbMaxPayload, []byte("host::features="+adbHostFeatures+"\x00"))
+	if err != nil {
+		return err
+	}
+
+	sentSignature, sentPublicKey := false, false
+	for {
+		err = dc.conn.SetReadDeadline(readDeadline(readTimeout))
+		if err != nil {
+			return err
+		}
+		msg, err := dc.readMessage()
+		if err != nil {
+			return err
+		}
+
+		switch msg.command {
+		case cmdCNXN:
+			if int(msg.arg1) < dc.maxPayload {
+				dc.maxPayload = int(msg.arg1)
+			}
+			dc.banner = strings.TrimRight(string(msg.data), "\x00")
+			return nil
+
+		case cmdAUTH:
+			if msg.arg0 != authToken {
+				return fmt.Errorf("unexpected auth type %d", msg.arg0)
+			}
+			if key == nil {
+				return ErrDeviceUnauthorized
+			}
+
+			switch {
+			case !sentSignature:
+				sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA1, msg.data)
+				if err != nil {
+					return fmt.Errorf("failed to sign auth token: %w", err)
+				}
+				err = dc.writeMessage(cmdAUTH, authSignature, 0, sig)
+				if err != nil {
+					return err
+				}
+				sentSignature = true
+
+			case !sentPublicKey:
+				// The key is unknown, wait for the user to accept it
+				pub := PublicKeyADBFormat(key)
+				err = dc.writeMessage(cmdAUTH, authRSAPublicKey, 0, append(pub, 0))
+				if err != nil {
+					return err
+				}
+				sentPublicKey = true
+
+			default:
+				return ErrDeviceUnauthorized
+			}
+
+		case cmdSTLS:
+			if tlsConfig == nil {
+				return errors.New("device requires TLS")
+			}
+			err = dc.writeMessage(cmdSTLS, msg.arg0, 0, nil)
+			if err != nil {
+				return err
+			}
+
+			tlsConn := tls.Client(dc.conn, tlsConfig)
+			err = dc.conn.SetDeadline(readDeadline(readTimeout))
+			if err != nil {
+				return err
+			}
+			err = tlsConn.Handshake()
+			if err != nil {
+				return fmt.Errorf("tls handshake: %w", err)
+			}
+			err = tlsConn.SetWriteDeadline(time.Time{})
+			if err != nil {
+				return err
+			}
+			dc.conn = tlsConn
+
+		default:
+			return fmt.Errorf("unexpected command %08x during handshake", msg.command)
+		}
+	}
+}
+
+func (dc *directConn) writeMessage(command, arg0, arg1 uint32, data []byte) error {
+	var checksum uint32
+	for _, b := range data {
+		checksum += uint32(b)
+	}
+
+	msg := new(bytes.Buffer)
+	for _, v := range []uint32{command, arg0, arg1, uint32(len(data)), checksum, command ^ 0xffffffff} {
+		_ = binary.Write(msg, binary.LittleEndian, v)
+	}
+	msg.Write(data)
+
+	dc.writeMu.Lock()
+	defer dc.writeMu.Unlock()
+	return _send(dc.conn, msg.Bytes())
+}
+
+func (dc *directConn) readMessage() (adbMessage, error) {
+	header, err := _readN(dc.conn, adbHeaderSize)
+	if err != nil {
+		return adbMessage{}, err
+	}
+
+	var fields [6]uint32
+	_ = binary.Read(bytes.NewReader(header), binary.LittleEndian, &fields)
+	if fields[5] != fields[0]^0xffffffff {
+		return adbMessage{}, fmt.Errorf("invalid message magic %08x", fields[5])
+	}
+
+	msg := adbMessage{command: fields[0], arg0: fields[1], arg1: fields[2]}
+	if fields[3] > adbPayloadLimit {
+		return adbMessage{}, fmt.Errorf("message payload of %d bytes exceeds %d", fields[3], adbPayloadLimit)
+	}
+	if fields[3] > 0 {
+		msg.data, err = _readN(dc.conn, int(fields[3]))
+		if err != nil {
+			return adbMessage{}, err
+		}
+	}
+	return msg, nil
+}
+
+// readLoop dispatches the incoming messages to their streams until the
+// connection breaks
+func (dc *directConn) readLoop() {
+	for {
+		msg, err := dc.readMessage()
+		if err != nil {
+			dc.closeWithError(err)
+			return
+		}
+
+		dc.mu.Lock()
+		s := dc.streams[msg.arg1]
+		dc.mu.Unlock()
+		if s == nil {
+			if msg.command == cmdWRTE || msg.command == cmdOKAY {
+				// Unknown stream, tell adbd to close its end
+				_ = dc.writeMessage(cmdCLSE, 0, msg.arg0, nil)
+			}
+			continue
+		}
+
+		switch msg.command {
+		case cmdOKAY:
+			s.handleOkay(msg.arg0)
+		case cmdWRTE:
+			s.handleWrite(msg.data)
+		case cmdCLSE:
+			s.handleClose()
+			dc.removeStream(s.localID)
+		}
+	}
+}
+
+func (dc *directConn) newStream() (*directStream, error) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	if dc.err != nil {
+		return nil, dc.err
+	}
+
+	dc.lastID++
+	s := &directStream{
+		dc:      dc,
+		localID: dc.lastID,
+		changed: make(chan struct{}),
+	}
+	dc.streams[s.localID] = s
+	return s, nil
+}
+
+func (dc *directConn) removeStream(id uint32) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	delete(dc.streams, id)
+}
+
+func (dc *directConn) closeWithError(err error) {
+	dc.mu.Lock()
+	if dc.err == nil {
+		dc.err = err
+	}
+	streams := dc.streams
+	dc.streams = map[uint32]*directStream{}
+	dc.mu.Unlock()
+
+	for _, s := range streams {
+		s.handleClose()
+	}
+}
+
+// Close closes the connection and all its streams
+func (dc *directConn) Close() error {
+	err := dc.conn.Close()
+	dc.closeWithError(ErrDirectClosed)
+	return err
+}
+
+var bannerAttrs = map[string]string{
+	"ro.product.name":   "product",
+	"ro.product.model":  "model",
+	"ro.product.device": "device",
+	"features":          "features",
+}
+
+// parseBanner parses the properties of the banner of adbd, e.g.
+// "device::ro.product.name=x;ro.product.model=y;features=shell_v2,cmd",
+// into the attributes reported by host:devices-l
+func parseBanner(banner string) map[string]string {
+	attrs := map[string]string{}
+	if i := strings.Index(banner, "::"); i >= 0 {
+		banner = banner[i+2:]
+	}
+
+	for _, prop := range strings.Split(banner, ";") {
+		kv := strings.SplitN(prop, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		if key, ok := bannerAttrs[kv[0]]; ok {
+			attrs[key] = kv[1]
+		}
+	}
+	return attrs
+}
+
+// directStream is a stream opened on a directConn. It behaves like a
+// connection to the adb server: the first request written opens the service
+// and is answered with OKAY or FAIL, so it can back a transport.
+type directStream struct {
+	dc      *directConn
+	localID uint32
+
+	mu           sync.Mutex
+	changed      chan struct{}
+	request      []byte
+	requested    bool
+	remoteID     uint32
+	opened       bool
+	closed       bool
+	buf          []byte
+	pendingAck   bool
+	writeAcked   bool
+	readDeadline time.Time
+}
+
+// notify wakes up all the waiters, s.mu must be held
+func (s *directStream) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *directStream) handleOkay(remoteID uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.opened {
+		s.opened = true
+		s.remoteID = remoteID
+		s.buf = append(s.buf, "OKAY"...)
+	} else {
+		s.writeAcked = true
+	}
+	s.notify()
+}
+
+func (s *directStream) handleWrite(data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buf = append(s.buf, data...)
+	s.pendingAck = true
+	s.notify()
+}
+
+func (s *directStream) handleClose() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	if !s.opened && s.requested {
+		msg := "closed"
+		s.buf = append(s.buf, fmt.Sprintf("FAIL%04x%s", len(msg), msg)...)
+	}
+	s.closed = true
+	s.notify()
+}
+
+// wait releases s.mu and blocks until the state changes, the deadline
+// passes or the stream closes, then acquires s.mu again
+func (s *directStream) wait(deadline time.Time) error {
+	changed := s.changed
+	s.mu.Unlock()
+	defer s.mu.Lock()
+
+	if deadline.IsZero() {
+		<-changed
+		return nil
+	}
+
+	timeout := time.Until(deadline)
+	if timeout <= 0 {
+		return os.ErrDeadlineExceeded
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-changed:
+		return nil
+	case <-timer.C:
+		return os.ErrDeadlineExceeded
+	}
+}
+
+func (s *directStream) Read(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for len(s.buf) == 0 && !s.closed {
+		if err := s.wait(s.readDeadline); err != nil {
+			return 0, err
+		}
+	}
+
+	if len(s.buf) == 0 {
+		return 0, io.EOF
+	}
+
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	if len(s.buf) == 0 && s.pendingAck && !s.closed {
+		// Ready for more data
+		s.pendingAck = false
+		if err := s.dc.writeMessage(cmdOKAY, s.localID, s.remoteID, nil); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func (s *directStream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	if !s.requested {
+		s.mu.Unlock()
+		return s.writeRequest(p)
+	}
+
+	for !s.opened && !s.closed {
+		_ = s.wait(time.Time{})
+	}
+	s.mu.Unlock()
+
+	written := 0
+	for written < len(p) {
+		chunk := p[written:]
+		if len(chunk) > s.dc.maxPayload {
+			chunk = chunk[:s.dc.maxPayload]
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return written, ErrConnBroken
+		}
+		s.writeAcked = false
+		remoteID := s.remoteID
+		s.mu.Unlock()
+
+		err := s.dc.writeMessage(cmdWRTE, s.localID, remoteID, chunk)
+		if err != nil {
+			return written, err
+		}
+
+		// Wait for adbd to acknowledge the data before sending more
+		s.mu.Lock()
+		for !s.writeAcked && !s.closed {
+			_ = s.wait(time.Time{})
+		}
+		s.mu.Unlock()
+
+		written += len(chunk)
+	}
+	return written, nil
+}
+
+// writeRequest buffers the length prefixed service request, opening the
+// service once it is complete
+func (s *directStream) writeRequest(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.request = append(s.request, p...)
+	if len(s.request) < 4 {
+		return len(p), nil
+	}
+
+	size, err := strconv.ParseUint(string(s.request[:4]), 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("adb direct: invalid request: %w", err)
+	}
+	if len(s.request) < 4+int(size) {
+		return len(p), nil
+	}
+	if len(s.request) > 4+int(size) {
+		return 0, errors.New("adb direct: data written before the service is opened")
+	}
+
+	service := append(s.request[4:], 0)
+	s.request = nil
+	s.requested = true
+	err = s.dc.writeMessage(cmdOPEN, s.localID, 0, service)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *directStream) Close() error {
+	s.mu.Lock()
+	alreadyClosed := s.closed
+	opened := s.opened
+	remoteID := s.remoteID
+	s.closed = true
+	s.notify()
+	s.mu.Unlock()
+
+	s.dc.removeStream(s.localID)
+	if alreadyClosed || !opened {
+		return nil
+	}
+	return s.dc.writeMessage(cmdCLSE, s.localID, remoteID, nil)
+}
+
+func (s *directStream) LocalAddr() net.Addr {
+	return s.dc.conn.LocalAddr()
+}
+
+func (s *directStream) RemoteAddr() net.Addr {
+	return s.dc.conn.RemoteAddr()
+}
+
+func (s *directStream) SetDeadline(t time.Time) error {
+	return s.SetReadDeadline(t)
+}
+
+func (s *directStream) SetReadDeadline(t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.readDeadline = t
+	s.notify()
+	return nil
+}
+
+func (s *directStream) SetWriteDeadline(time.Time) error {
+	return nil
+}
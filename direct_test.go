@@ -0,0 +1,132 @@
+package gadb
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestConnectDirect_fakeDevice(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, adbKeyBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, server := net.Pipe()
+	defer client.Close()
+
+	go func() {
+		defer server.Close()
+		adbd := &directConn{conn: server}
+
+		expect := func(command uint32) adbMessage {
+			msg, err := adbd.readMessage()
+			if err != nil {
+				t.Error(err)
+				return adbMessage{}
+			}
+			if msg.command != command {
+				t.Errorf("command = %08x, want %08x", msg.command, command)
+			}
+			return msg
+		}
+
+		expect(cmdCNXN)
+
+		token := bytes.Repeat([]byte{42}, 20)
+		_ = adbd.writeMessage(cmdAUTH, authToken, 0, token)
+		msg := expect(cmdAUTH)
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA1, token, msg.data); err != nil {
+			t.Errorf("invalid signature: %v", err)
+		}
+		_ = adbd.writeMessage(cmdCNXN, adbVersion, 4096, []byte("device::ro.product.model=Fake;features=shell_v2,cmd"))
+
+		msg = expect(cmdOPEN)
+		if string(msg.data) != "shell:echo hi\x00" {
+			t.Errorf("service = %q", msg.data)
+		}
+		localID := msg.arg0
+		_ = adbd.writeMessage(cmdOKAY, 100, localID, nil)
+		_ = adbd.writeMessage(cmdWRTE, 100, localID, []byte("hi\n"))
+		expect(cmdOKAY)
+		_ = adbd.writeMessage(cmdCLSE, 100, localID, nil)
+	}()
+
+	dc, err := newDirectConn(client, key, nil, defaultAdbReadTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dc.Close()
+
+	d := Device{
+		adbClient: Client{readTimeout: defaultAdbReadTimeout},
+		serial:    "fake",
+		attrs:     parseBanner(dc.banner),
+		direct:    dc,
+	}
+
+	model, err := d.Model()
+	if err != nil || model != "Fake" {
+		t.Errorf("Model() = %q, %v", model, err)
+	}
+
+	if ok, _ := d.HasFeature("shell_v2"); !ok {
+		t.Error("HasFeature(shell_v2) = false, want true")
+	}
+
+	output, err := d.RunShellCommand("echo", "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output != "hi\n" {
+		t.Errorf("RunShellCommand() = %q, want %q", output, "hi\n")
+	}
+}
+
+func TestNewDirectConn_silentDevice(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, adbKeyBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	go func() {
+		// Reads the CNXN, never answers
+		_, _ = io.Copy(ioutil.Discard, server)
+	}()
+
+	_, err = newDirectConn(client, key, nil, 50*time.Millisecond)
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("newDirectConn() error = %v, want a timeout", err)
+	}
+}
+
+func TestDirectConn_readMessage_payloadLimit(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		var header bytes.Buffer
+		for _, v := range []uint32{cmdWRTE, 1, 1, adbPayloadLimit + 1, 0, cmdWRTE ^ 0xffffffff} {
+			_ = binary.Write(&header, binary.LittleEndian, v)
+		}
+		_, _ = server.Write(header.Bytes())
+	}()
+
+	dc := &directConn{conn: client}
+	if _, err := dc.readMessage(); err == nil {
+		t.Error("readMessage() accepted a payload over the limit")
+	}
+}
@@ -0,0 +1,52 @@
+package gadb
+
+import (
+	"errors"
+	"strings"
+)
+
+// List of errors reported by the adb server
+var (
+	ErrDeviceNotFound     = errors.New("device not found")
+	ErrDeviceOffline      = errors.New("device offline")
+	ErrDeviceUnauthorized = errors.New("device unauthorized")
+	ErrWrongPairingCode   = errors.New("wrong pairing code")
+	ErrHostUnreachable    = errors.New("host unreachable")
+	ErrMoreThanOneDevice  = errors.New("more than one device")
+)
+
+// AdbError is returned when the adb server answers a request with FAIL.
+// Known failures wrap one of the sentinel errors, so they can be checked
+// with errors.Is.
+type AdbError struct {
+	// Message is the raw message sent by the adb server
+	Message string
+
+	err error
+}
+
+func (e *AdbError) Error() string {
+	return "command failed: " + e.Message
+}
+
+func (e *AdbError) Unwrap() error {
+	return e.err
+}
+
+func newAdbError(message string) *AdbError {
+	e := &AdbError{Message: message}
+
+	switch {
+	case strings.HasPrefix(message, "device '") && strings.HasSuffix(message, "' not found"),
+		strings.HasPrefix(message, "no devices"),
+		strings.HasPrefix(message, "no emulators"):
+		e.err = ErrDeviceNotFound
+	case strings.HasPrefix(message, "more than one "):
+		e.err = ErrMoreThanOneDevice
+	case strings.HasPrefix(message, "device offline"):
+		e.err = ErrDeviceOffline
+	case strings.HasPrefix(message, "device unauthorized"):
+		e.err = ErrDeviceUnauthorized
+	}
+	return e
+}
@@ -0,0 +1,33 @@
+package gadb
+
+import (
+	"errors"
+	"testing"
+)
+
+func Test_newAdbError(t *testing.T) {
+	tests := []struct {
+		message string
+		want    error
+	}{
+		{message: "device 'emulator-5554' not found", want: ErrDeviceNotFound},
+		{message: "no devices/emulators found", want: ErrDeviceNotFound},
+		{message: "device offline", want: ErrDeviceOffline},
+		{message: "more than one device/emulator", want: ErrMoreThanOneDevice},
+		{message: "device unauthorized.\nThis adb server's $ADB_VENDOR_KEYS is not set", want: ErrDeviceUnauthorized},
+		{message: "unknown host service"},
+	}
+
+	for _, tt := range tests {
+		err := newAdbError(tt.message)
+		if err.Message != tt.message {
+			t.Errorf("Message = %q, want %q", err.Message, tt.message)
+		}
+		if tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("newAdbError(%q) = %v, want %v", tt.message, err, tt.want)
+		}
+		if tt.want == nil && errors.Unwrap(err) != nil {
+			t.Errorf("newAdbError(%q) wraps %v, want nil", tt.message, errors.Unwrap(err))
+		}
+	}
+}
@@ -6,14 +6,37 @@ import (
 )
 
 const (
-	dirBit = 1 << 14
+	dirBit     = 1 << 14
+	regularBit = 1 << 15
+	symlinkBit = regularBit | 1<<13
+	typeMask   = 0o170000
+
+	setuidBit = 0o4000
+	setgidBit = 0o2000
+	stickyBit = 0o1000
 )
 
+// FileStat is the extended file information reported by the sync v2
+// protocol, available through os.FileInfo.Sys() on devices supporting it
+type FileStat struct {
+	Dev   uint64
+	Ino   uint64
+	Mode  uint32
+	Nlink uint32
+	UID   uint32
+	GID   uint32
+	Size  int64
+	Atime time.Time
+	Mtime time.Time
+	Ctime time.Time
+}
+
 type fileInfo struct {
 	name    string
 	mode    os.FileMode
-	size    uint32
+	size    int64
 	modTime time.Time
+	stat    *FileStat
 }
 
 func (f fileInfo) Name() string {
@@ -21,7 +44,7 @@ func (f fileInfo) Name() string {
 }
 
 func (f fileInfo) Size() int64 {
-	return int64(f.size)
+	return f.size
 }
 
 func (f fileInfo) Mode() os.FileMode {
@@ -37,5 +60,48 @@ func (f fileInfo) IsDir() bool {
 }
 
 func (f fileInfo) Sys() interface{} {
-	return nil
+	if f.stat == nil {
+		return nil
+	}
+	return f.stat
+}
+
+func (f fileInfo) isRegular() bool {
+	return f.mode&typeMask == regularBit
+}
+
+// posixMode converts mode to the POSIX st_mode expected by adbd, os.FileMode
+// keeping the file type and special bits elsewhere
+func posixMode(mode os.FileMode) uint32 {
+	m := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		m |= setuidBit
+	}
+	if mode&os.ModeSetgid != 0 {
+		m |= setgidBit
+	}
+	if mode&os.ModeSticky != 0 {
+		m |= stickyBit
+	}
+
+	if mode&os.ModeSymlink != 0 {
+		return m | symlinkBit
+	}
+	return m | regularBit
+}
+
+// fileMode converts the POSIX st_mode reported by adbd to the permission and
+// special bits of an os.FileMode, the inverse of posixMode without the type
+func fileMode(mode uint32) os.FileMode {
+	m := os.FileMode(mode).Perm()
+	if mode&setuidBit != 0 {
+		m |= os.ModeSetuid
+	}
+	if mode&setgidBit != 0 {
+		m |= os.ModeSetgid
+	}
+	if mode&stickyBit != 0 {
+		m |= os.ModeSticky
+	}
+	return m
 }
@@ -8,3 +8,35 @@ import (
 func TestFileInfo_osFileInfo(_ *testing.T) {
 	_ = os.FileInfo(fileInfo{})
 }
+
+func Test_posixMode(t *testing.T) {
+	tests := map[os.FileMode]uint32{
+		0o644:                              0o100644,
+		0o755:                              0o100755,
+		os.ModeSetuid | 0o755:              0o104755,
+		os.ModeSticky | os.ModeDir | 0o777: 0o101777,
+		os.ModeSymlink | 0o777:             0o120777,
+	}
+
+	for mode, want := range tests {
+		if got := posixMode(mode); got != want {
+			t.Errorf("posixMode(%v) = %o, want %o", mode, got, want)
+		}
+	}
+}
+
+func Test_fileMode(t *testing.T) {
+	tests := map[uint32]os.FileMode{
+		0o100644: 0o644,
+		0o104755: os.ModeSetuid | 0o755,
+		0o102755: os.ModeSetgid | 0o755,
+		0o041777: os.ModeSticky | 0o777,
+		0o120777: 0o777,
+	}
+
+	for mode, want := range tests {
+		if got := fileMode(mode); got != want {
+			t.Errorf("fileMode(%o) = %v, want %v", mode, got, want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package gadb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DeviceErrors holds the errors of ForEachDevice, keyed by device serial
+type DeviceErrors map[string]error
+
+func (e DeviceErrors) Error() string {
+	serials := make([]string, 0, len(e))
+	for serial := range e {
+		serials = append(serials, serial)
+	}
+	sort.Strings(serials)
+
+	msgs := make([]string, len(serials))
+	for i, serial := range serials {
+		msgs[i] = fmt.Sprintf("%s: %v", serial, e[serial])
+	}
+	return strings.Join(msgs, ", ")
+}
+
+// ForEachDevice lists the devices and runs fn on each of them, at most
+// concurrency at a time, or all at once if concurrency is not positive. The
+// devices not started yet when ctx is done are skipped with ctx.Err(). The
+// errors are returned as DeviceErrors once every call has returned.
+func (c Client) ForEachDevice(ctx context.Context, concurrency int, fn func(Device) error) error {
+	devices, err := c.List()
+	var warnings ErrWarnings
+	if err != nil && !errors.As(err, &warnings) {
+		return err
+	}
+	if concurrency <= 0 || concurrency > len(devices) {
+		concurrency = len(devices)
+	}
+
+	var mu sync.Mutex
+	errs := DeviceErrors{}
+	setErr := func(serial string, err error) {
+		mu.Lock()
+		errs[serial] = err
+		mu.Unlock()
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for _, d := range devices {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		// Even with a free slot, do not start once ctx is done
+		if ctx.Err() != nil {
+			setErr(d.serial, ctx.Err())
+			continue
+		}
+
+		wg.Add(1)
+		go func(d Device) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := fn(d); err != nil {
+				setErr(d.serial, err)
+			}
+		}(d)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
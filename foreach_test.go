@@ -0,0 +1,70 @@
+package gadb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClient_ForEachDevice(t *testing.T) {
+	devices := "serial-1 device product:sdk model:A device:generic transport_id:1\n" +
+		"serial-2 device product:sdk model:B device:generic transport_id:2\n" +
+		"serial-3 device product:sdk model:C device:generic transport_id:3\n"
+
+	c, err := NewClientWithHost("fake", WithDialer(fakeServer(t, func(request string) string {
+		switch request {
+		case "host:version":
+			return "OKAY00040029"
+		case "host:devices-l":
+			return fmt.Sprintf("OKAY%04x%s", len(devices), devices)
+		}
+		return "FAIL0007unknown"
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errFailed := errors.New("failed")
+	var mu sync.Mutex
+	running, maxRunning, calls := 0, 0, 0
+	err = c.ForEachDevice(context.Background(), 2, func(d Device) error {
+		mu.Lock()
+		calls++
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		if d.Serial() == "serial-2" {
+			return errFailed
+		}
+		return nil
+	})
+
+	var errs DeviceErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs["serial-2"] != errFailed {
+		t.Errorf("ForEachDevice() error = %v", err)
+	}
+	if calls != 3 || maxRunning != 2 {
+		t.Errorf("ForEachDevice() ran %d calls, %d at once, want 3 and 2", calls, maxRunning)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = c.ForEachDevice(ctx, 0, func(d Device) error {
+		t.Errorf("fn called on %s after cancel", d.Serial())
+		return nil
+	})
+	if !errors.As(err, &errs) || len(errs) != 3 || !errors.Is(errs["serial-1"], context.Canceled) {
+		t.Errorf("ForEachDevice() after cancel error = %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package gadb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// framebufferHeader is the header of the framebuffer: service, describing
+// the pixels that follow it
+type framebufferHeader struct {
+	bpp           uint32
+	size          uint32
+	width, height uint32
+	// red, green, blue and alpha are the offset and length in bits of the
+	// channels within a little endian pixel
+	red, green, blue, alpha [2]uint32
+}
+
+// Framebuffer returns the screen of the device read with the framebuffer:
+// service, which is faster than screencap on some devices
+func (d Device) Framebuffer() (image.Image, error) {
+	rw, err := d.executeCommandStreaming("framebuffer:")
+	if err != nil {
+		return nil, err
+	}
+	defer rw.Close()
+
+	r := bufio.NewReader(rw)
+	header, err := readFramebufferHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Old devices wait for a byte before sending the pixels
+	_, _ = rw.Write([]byte{0})
+
+	pixels := make([]byte, header.size)
+	_, err = io.ReadFull(r, pixels)
+	if err != nil {
+		return nil, fmt.Errorf("adb framebuffer: failed to read pixels: %w", err)
+	}
+	return header.image(pixels)
+}
+
+// readFramebufferHeader reads the header of the framebuffer: service. Version
+// 1 follows the version with bpp, size, width, height, then the offset and
+// length of the red, blue, green and alpha channels. Version 2 adds a color
+// space after bpp. The legacy version 16 is RGB565 with size, width and
+// height only.
+func readFramebufferHeader(r io.Reader) (framebufferHeader, error) {
+	var version uint32
+	err := binary.Read(r, binary.LittleEndian, &version)
+	if err != nil {
+		return framebufferHeader{}, fmt.Errorf("adb framebuffer: failed to read header: %w", err)
+	}
+
+	var fields []uint32
+	switch version {
+	case 16:
+		fields = make([]uint32, 3)
+	case 1:
+		fields = make([]uint32, 12)
+	case 2:
+		fields = make([]uint32, 13)
+	default:
+		return framebufferHeader{}, fmt.Errorf("adb framebuffer: unsupported version %d", version)
+	}
+	err = binary.Read(r, binary.LittleEndian, fields)
+	if err != nil {
+		return framebufferHeader{}, fmt.Errorf("adb framebuffer: failed to read header: %w", err)
+	}
+
+	if version == 16 {
+		return framebufferHeader{
+			bpp:  16,
+			size: fields[0], width: fields[1], height: fields[2],
+			red: [2]uint32{11, 5}, green: [2]uint32{5, 6}, blue: [2]uint32{0, 5},
+		}, nil
+	}
+	if version == 2 {
+		// Drop the color space
+		fields = append(fields[:1], fields[2:]...)
+	}
+	return framebufferHeader{
+		bpp:  fields[0],
+		size: fields[1], width: fields[2], height: fields[3],
+		red:   [2]uint32{fields[4], fields[5]},
+		blue:  [2]uint32{fields[6], fields[7]},
+		green: [2]uint32{fields[8], fields[9]},
+		alpha: [2]uint32{fields[10], fields[11]},
+	}, nil
+}
+
+// image converts the pixels to an RGBA image, scaling the channels to 8 bits
+func (h framebufferHeader) image(pixels []byte) (image.Image, error) {
+	bytesPerPixel := int(h.bpp / 8)
+	if h.bpp%8 != 0 || bytesPerPixel == 0 || bytesPerPixel > 4 {
+		return nil, fmt.Errorf("adb framebuffer: unsupported bpp %d", h.bpp)
+	}
+	width, height := int(h.width), int(h.height)
+	if len(pixels) < width*height*bytesPerPixel {
+		return nil, fmt.Errorf("adb framebuffer: %d bytes of pixels for %dx%d at %d bpp", len(pixels), width, height, h.bpp)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := (y*width + x) * bytesPerPixel
+			var v uint32
+			for b := 0; b < bytesPerPixel; b++ {
+				v |= uint32(pixels[i+b]) << (8 * b)
+			}
+
+			a := uint8(0xff)
+			if h.alpha[1] != 0 {
+				a = channel(v, h.alpha)
+			}
+			img.SetRGBA(x, y, color.RGBA{R: channel(v, h.red), G: channel(v, h.green), B: channel(v, h.blue), A: a})
+		}
+	}
+	return img, nil
+}
+
+// channel extracts the channel at offset and length from the pixel value,
+// scaled to 8 bits
+func channel(v uint32, offsetLength [2]uint32) uint8 {
+	offset, length := offsetLength[0], offsetLength[1]
+	if length == 0 {
+		return 0
+	}
+	max := uint32(1)<<length - 1
+	return uint8((v >> offset & max) * 0xff / max)
+}
@@ -0,0 +1,70 @@
+package gadb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"testing"
+)
+
+func framebufferData(t *testing.T, header []uint32, pixels []byte) *bytes.Reader {
+	var b bytes.Buffer
+	if err := binary.Write(&b, binary.LittleEndian, header); err != nil {
+		t.Fatal(err)
+	}
+	b.Write(pixels)
+	return bytes.NewReader(b.Bytes())
+}
+
+func Test_readFramebufferHeader(t *testing.T) {
+	// Two pixels: opaque red then semi-transparent blue
+	rgba := []byte{0xff, 0, 0, 0xff, 0, 0, 0xff, 0x80}
+	rgba8888 := []uint32{32, 8, 2, 1, 0, 8, 16, 8, 8, 8, 24, 8}
+	// Blue then green, little endian RGB565
+	rgb565 := []byte{0x1f, 0x00, 0xe0, 0x07}
+
+	tests := map[string]struct {
+		data *bytes.Reader
+		want []color.RGBA
+	}{
+		"version 1": {
+			data: framebufferData(t, append([]uint32{1}, rgba8888...), rgba),
+			want: []color.RGBA{{R: 0xff, A: 0xff}, {B: 0xff, A: 0x80}},
+		},
+		"version 2": {
+			data: framebufferData(t, append([]uint32{2, 32, 0}, rgba8888[1:]...), rgba),
+			want: []color.RGBA{{R: 0xff, A: 0xff}, {B: 0xff, A: 0x80}},
+		},
+		"version 16": {
+			data: framebufferData(t, []uint32{16, 4, 2, 1}, rgb565),
+			want: []color.RGBA{{B: 0xff, A: 0xff}, {G: 0xff, A: 0xff}},
+		},
+	}
+
+	for name, tt := range tests {
+		header, err := readFramebufferHeader(tt.data)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		pixels := make([]byte, header.size)
+		if _, err := tt.data.Read(pixels); err != nil {
+			t.Fatal(err)
+		}
+
+		img, err := header.image(pixels)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		for x, want := range tt.want {
+			if got := img.At(x, 0); got != want {
+				t.Errorf("%s: pixel %d = %v, want %v", name, x, got, want)
+			}
+		}
+	}
+
+	if _, err := readFramebufferHeader(framebufferData(t, []uint32{3}, nil)); err == nil {
+		t.Error("expected error for an unsupported version")
+	}
+}
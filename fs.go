@@ -0,0 +1,127 @@
+package gadb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// Mkdir creates the remote directory path with the permission bits perm,
+// along with any missing parent, like mkdir -p
+func (d Device) Mkdir(path string, perm os.FileMode) error {
+	return d.runFileCommand("mkdir", path, "mkdir", "-p", "-m", fmt.Sprintf("%o", perm.Perm()), path)
+}
+
+// Remove removes the remote file or empty directory path
+func (d Device) Remove(path string) error {
+	info, err := d.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return d.runFileCommand("remove", path, "rmdir", path)
+	}
+	return d.runFileCommand("remove", path, "rm", path)
+}
+
+// RemoveAll removes the remote path and any children it contains, like
+// rm -rf. It returns nil if path does not exist.
+func (d Device) RemoveAll(path string) error {
+	return d.runFileCommand("remove", path, "rm", "-rf", path)
+}
+
+// Rename moves the remote oldPath to newPath
+func (d Device) Rename(oldPath, newPath string) error {
+	err := d.runFileCommand("rename", oldPath, "mv", oldPath, newPath)
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: pathErr.Err}
+	}
+	return err
+}
+
+// Copy copies the remote file srcPath to dstPath, keeping its mode and
+// modification time, like cp -p. Devices without cp get the content copied
+// with cat, the mode and times of dstPath are not kept then.
+func (d Device) Copy(srcPath, dstPath string) error {
+	err := d.runFileCommand("copy", srcPath, "cp", "-p", srcPath, dstPath)
+	if !isCpNotFound(err) {
+		return err
+	}
+	return d.catCopy(srcPath, dstPath)
+}
+
+// CopyDir copies the remote directory srcPath and its content to dstPath,
+// like cp -rp. Devices without cp get the tree copied with mkdir and cat,
+// symbolic links are skipped then.
+func (d Device) CopyDir(srcPath, dstPath string) error {
+	err := d.runFileCommand("copy", srcPath, "cp", "-rp", srcPath, dstPath)
+	if !isCpNotFound(err) {
+		return err
+	}
+
+	srcPath = path.Clean(srcPath)
+	return d.Walk(srcPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		target := path.Join(dstPath, strings.TrimPrefix(p, srcPath))
+		switch {
+		case info.IsDir():
+			return d.Mkdir(target, info.Mode().Perm())
+		case info.(fileInfo).isRegular():
+			return d.catCopy(p, target)
+		}
+		return nil
+	})
+}
+
+func (d Device) catCopy(srcPath, dstPath string) error {
+	return d.runFileCommand("copy", srcPath, "cat "+shellQuote(srcPath)+" > "+shellQuote(dstPath))
+}
+
+// isCpNotFound reports whether err is the shell failing to find cp, e.g.
+// "/system/bin/sh: cp: not found"
+func isCpNotFound(err error) bool {
+	var pathErr *os.PathError
+	if !errors.As(err, &pathErr) {
+		return false
+	}
+	msg := pathErr.Err.Error()
+	return strings.Contains(msg, "cp: not found") || strings.Contains(msg, "cp: inaccessible or not found")
+}
+
+// AppendFile appends data to the remote file, creating it if needed. The sync
+// protocol always truncates, so data is piped to cat instead, through shell
+// v2 or exec: which keep it binary clean.
+func (d Device) AppendFile(remotePath string, data io.Reader) error {
+	result, err := d.runShellV2(context.Background(), "cat >> "+shellQuote(remotePath), data)
+	if err != nil {
+		return fmt.Errorf("adb append %s: %w", remotePath, err)
+	}
+	return shellPathError("append", remotePath, result)
+}
+
+// runFileCommand runs a command printing nothing on success. The error
+// output is returned as an *os.PathError otherwise.
+func (d Device) runFileCommand(op, path, cmd string, args ...string) error {
+	result, err := d.RunShellV2(context.Background(), cmd, args...)
+	if err != nil {
+		return fmt.Errorf("adb %s %s: %w", op, path, err)
+	}
+	return shellPathError(op, path, result)
+}
+
+// shellPathError returns the error output of a command printing nothing on
+// success as an *os.PathError, nil if there is none
+func shellPathError(op, path string, result ShellResult) error {
+	msg := result.failure()
+	if msg == "" {
+		return nil
+	}
+	return &os.PathError{Op: op, Path: path, Err: errors.New(msg)}
+}
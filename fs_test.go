@@ -0,0 +1,264 @@
+package gadb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// fakeFile is a file of the fake device of syncServer, mode being the POSIX
+// st_mode reported by adbd
+type fakeFile struct {
+	mode uint32
+	data string
+}
+
+// syncServer returns a dialer to an in-memory adb server answering the sync
+// STAT, LIST and RECV requests from files, keyed by path, and running shell
+// commands with run. The fake device supports no feature.
+func syncServer(t *testing.T, files map[string]fakeFile, run func(cmd string) string) DialFunc {
+	return fakeServer(t, func(request string) string {
+		switch {
+		case strings.HasSuffix(request, ":features"):
+			return "OKAY0000"
+		case strings.HasPrefix(request, "host:transport"), request == "sync:":
+			return "OKAY"
+		case strings.HasPrefix(request, "shell:"):
+			return "OKAY" + run(strings.TrimPrefix(request, "shell:"))
+		}
+		return fmt.Sprintf("FAIL%04xunknown", len("unknown"))
+	}, func(request string, conn net.Conn) {
+		if request != "sync:" {
+			return
+		}
+		for {
+			header := make([]byte, 8)
+			if _, err := io.ReadFull(conn, header); err != nil {
+				return
+			}
+			name := make([]byte, binary.LittleEndian.Uint32(header[4:]))
+			if _, err := io.ReadFull(conn, name); err != nil {
+				return
+			}
+
+			var resp bytes.Buffer
+			switch string(header[:4]) {
+			case "STAT":
+				f := files[string(name)]
+				resp.WriteString("STAT")
+				binary.Write(&resp, binary.LittleEndian, [3]uint32{f.mode, uint32(len(f.data)), 0})
+			case "LIST":
+				var names []string
+				for p := range files {
+					if path.Dir(p) == string(name) {
+						names = append(names, p)
+					}
+				}
+				sort.Strings(names)
+				for _, p := range names {
+					f := files[p]
+					resp.WriteString("DENT")
+					binary.Write(&resp, binary.LittleEndian, [4]uint32{f.mode, uint32(len(f.data)), 0, uint32(len(path.Base(p)))})
+					resp.WriteString(path.Base(p))
+				}
+				resp.WriteString("DONE")
+				binary.Write(&resp, binary.LittleEndian, [4]uint32{})
+			case "RECV":
+				f := files[string(name)]
+				resp.WriteString("DATA")
+				binary.Write(&resp, binary.LittleEndian, uint32(len(f.data)))
+				resp.WriteString(f.data)
+				resp.WriteString("DONE")
+				binary.Write(&resp, binary.LittleEndian, uint32(0))
+			default:
+				return
+			}
+			if _, err := conn.Write(resp.Bytes()); err != nil {
+				return
+			}
+		}
+	})
+}
+
+func TestDevice_Mkdir_Rename_Remove(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+	d := devices[0]
+
+	root := "/data/local/tmp/gadb-fs"
+	defer d.RemoveAll(root)
+
+	if err := d.Mkdir(root+"/a/b", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Rename(root+"/a/b", root+"/c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Remove(root + "/c"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Stat(root + "/c"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected removed directory, got %v", err)
+	}
+
+	var pathErr *os.PathError
+	if err := d.Remove(root); !errors.As(err, &pathErr) {
+		t.Errorf("expected *os.PathError removing a non empty directory, got %v", err)
+	}
+
+	if err := d.RemoveAll(root); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.RemoveAll(root); err != nil {
+		t.Errorf("RemoveAll() of a missing path: %v", err)
+	}
+}
+
+func TestDevice_AppendFile(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+	d := devices[0]
+
+	remotePath := "/data/local/tmp/gadb-append.bin"
+	defer d.RemoveAll(remotePath)
+
+	first := []byte("line\r\nnul\x00\n")
+	second := []byte{0x00, 0xff, '\n', 0x1a, 0x04}
+	if err := d.AppendFile(remotePath, bytes.NewReader(first)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.AppendFile(remotePath, bytes.NewReader(second)); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := d.ReadFile(remotePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := append(first, second...); !bytes.Equal(got, want) {
+		t.Errorf("ReadFile() = %q, want %q", got, want)
+	}
+
+	var pathErr *os.PathError
+	if err := d.AppendFile("/gadb-missing/append.bin", bytes.NewReader(first)); !errors.As(err, &pathErr) {
+		t.Errorf("expected *os.PathError, got %v", err)
+	}
+}
+
+func Test_isCpNotFound(t *testing.T) {
+	tests := map[string]bool{
+		"/system/bin/sh: cp: not found":                    true,
+		"/system/bin/sh: cp: inaccessible or not found":    true,
+		"cp: /data/local/tmp/a: No such file or directory": false,
+	}
+
+	for msg, want := range tests {
+		err := &os.PathError{Op: "copy", Path: "a", Err: errors.New(msg)}
+		if got := isCpNotFound(err); got != want {
+			t.Errorf("isCpNotFound(%q) = %v, want %v", msg, got, want)
+		}
+	}
+}
+
+func TestDevice_CopyDir(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+	d := devices[0]
+
+	root := "/data/local/tmp/gadb-copy"
+	defer d.RemoveAll(root)
+
+	if err := d.Mkdir(root+"/src/sub", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.AppendFile(root+"/src/sub/file.txt", strings.NewReader("content")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.CopyDir(root+"/src", root+"/dst"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Copy(root+"/dst/sub/file.txt", root+"/copy.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := d.ReadFile(root + "/copy.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "content" {
+		t.Errorf("ReadFile() = %q, want %q", got, "content")
+	}
+}
+
+func TestDevice_CopyDir_fallback(t *testing.T) {
+	files := map[string]fakeFile{
+		"/sdcard/src":      {mode: 0o040755},
+		"/sdcard/src/fifo": {mode: 0o010644},
+		"/sdcard/src/file": {mode: 0o100644, data: "hello"},
+		"/sdcard/src/link": {mode: 0o120777},
+	}
+	var cmds []string
+	d := Device{adbClient: Client{readTimeout: defaultAdbReadTimeout, dial: syncServer(t, files, func(cmd string) string {
+		cmds = append(cmds, cmd)
+		if strings.HasPrefix(cmd, "cp ") {
+			return "/system/bin/sh: cp: not found\n"
+		}
+		return ""
+	})}, serial: "fake"}
+
+	if err := d.CopyDir("/sdcard/src", "/sdcard/dst"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"cp -rp /sdcard/src /sdcard/dst",
+		"mkdir -p -m 755 /sdcard/dst",
+		"cat /sdcard/src/file > /sdcard/dst/file",
+	}
+	if !reflect.DeepEqual(cmds, want) {
+		t.Errorf("ran %q, want %q", cmds, want)
+	}
+}
@@ -0,0 +1,55 @@
+package gadb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// InputTap taps the screen at the given coordinates
+func (d Device) InputTap(x, y int) error {
+	return d.input(fmt.Sprintf("tap %d %d", x, y))
+}
+
+// InputSwipe swipes from (x1, y1) to (x2, y2) in durationMs milliseconds
+func (d Device) InputSwipe(x1, y1, x2, y2, durationMs int) error {
+	return d.input(fmt.Sprintf("swipe %d %d %d %d %d", x1, y1, x2, y2, durationMs))
+}
+
+// InputText types the text into the focused field
+func (d Device) InputText(s string) error {
+	return d.input("text " + escapeInputText(s))
+}
+
+// InputKeyevent sends a key event, see android.view.KeyEvent for the codes
+func (d Device) InputKeyevent(code int) error {
+	return d.input(fmt.Sprintf("keyevent %d", code))
+}
+
+func (d Device) input(args string) error {
+	output, err := d.RunShellCommand("input " + args)
+	if err != nil {
+		return fmt.Errorf("adb input: %w", err)
+	}
+	if output = strings.TrimSpace(output); output != "" {
+		return fmt.Errorf("adb input: %s", output)
+	}
+	return nil
+}
+
+// escapeInputText escapes s for `input text`, which reads "%s" as a space,
+// and for the device shell
+func escapeInputText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case ' ':
+			b.WriteString("%s")
+		case '\\', '\'', '"', '`', '$', '&', '|', ';', '<', '>', '(', ')', '[', ']', '{', '}', '*', '?', '!', '~', '#':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,43 @@
+package gadb
+
+import (
+	"testing"
+)
+
+func Test_escapeInputText(t *testing.T) {
+	tests := map[string]string{
+		"hello":         "hello",
+		"hello world":   "hello%sworld",
+		`it's "quoted"`: `it\'s%s\"quoted\"`,
+		"a;b&c|d":       `a\;b\&c\|d`,
+		"$(reboot)":     `\$\(reboot\)`,
+	}
+
+	for s, want := range tests {
+		if got := escapeInputText(s); got != want {
+			t.Errorf("escapeInputText(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
+
+func TestDevice_InputKeyevent(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	// KEYCODE_UNKNOWN
+	err = devices[0].InputKeyevent(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,134 @@
+package gadb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Status codes of the instrumentation of a test by AndroidJUnitRunner
+const (
+	InstrumentStatusStart      = 1
+	InstrumentStatusOK         = 0
+	InstrumentStatusFailure    = -2
+	InstrumentStatusIgnored    = -3
+	InstrumentStatusAssumption = -4
+)
+
+// InstrumentStatus is a status reported by an instrumentation while it runs,
+// e.g. the start or the end of a test
+type InstrumentStatus struct {
+	// Code is the INSTRUMENTATION_STATUS_CODE, see the InstrumentStatus* constants
+	Code int
+	// Values are the INSTRUMENTATION_STATUS key/values, such as class, test or stack
+	Values map[string]string
+}
+
+// InstrumentResult is the final result of an instrumentation
+type InstrumentResult struct {
+	// Code is the INSTRUMENTATION_CODE, -1 (Activity.RESULT_OK) on success
+	Code int
+	// Values are the INSTRUMENTATION_RESULT key/values, such as stream
+	Values map[string]string
+}
+
+// Instrument runs the instrumentation component (package/runner) with
+// am instrument and waits for it to finish, calling onStatus (if not nil)
+// with every status it reports. args are passed with -e.
+func (d Device) Instrument(ctx context.Context, component string, args map[string]string, onStatus func(InstrumentStatus)) (InstrumentResult, error) {
+	cmdArgs := []string{"instrument", "-w", "-r"}
+	keys := make([]string, 0, len(args))
+	for k := range args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		cmdArgs = append(cmdArgs, "-e", k, args[k])
+	}
+	cmdArgs = append(cmdArgs, component)
+
+	p := newInstrumentParser(onStatus)
+	err := d.RunShellCommandLines(ctx, func(line string) bool {
+		p.parseLine(line)
+		return true
+	}, "am", cmdArgs...)
+	if err != nil {
+		return InstrumentResult{}, fmt.Errorf("adb instrument %s: %w", component, err)
+	}
+	return p.finish(component)
+}
+
+// instrumentParser parses the output of am instrument -r line by line
+type instrumentParser struct {
+	onStatus func(InstrumentStatus)
+
+	status  map[string]string
+	result  map[string]string
+	code    *int
+	failure string
+
+	// last holds the value continued by lines without prefix
+	last    map[string]string
+	lastKey string
+}
+
+func newInstrumentParser(onStatus func(InstrumentStatus)) *instrumentParser {
+	return &instrumentParser{
+		onStatus: onStatus,
+		status:   map[string]string{},
+		result:   map[string]string{},
+	}
+}
+
+func (p *instrumentParser) parseLine(line string) {
+	switch {
+	case strings.HasPrefix(line, "INSTRUMENTATION_STATUS: "):
+		p.setValue(p.status, strings.TrimPrefix(line, "INSTRUMENTATION_STATUS: "))
+
+	case strings.HasPrefix(line, "INSTRUMENTATION_STATUS_CODE: "):
+		code, _ := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "INSTRUMENTATION_STATUS_CODE: ")))
+		if p.onStatus != nil {
+			p.onStatus(InstrumentStatus{Code: code, Values: p.status})
+		}
+		p.status = map[string]string{}
+		p.last = nil
+
+	case strings.HasPrefix(line, "INSTRUMENTATION_RESULT: "):
+		p.setValue(p.result, strings.TrimPrefix(line, "INSTRUMENTATION_RESULT: "))
+
+	case strings.HasPrefix(line, "INSTRUMENTATION_CODE: "):
+		code, _ := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "INSTRUMENTATION_CODE: ")))
+		p.code = &code
+		p.last = nil
+
+	case strings.HasPrefix(line, "INSTRUMENTATION_FAILED: "),
+		strings.HasPrefix(line, "INSTRUMENTATION_ABORTED: "):
+		p.failure = line
+		p.last = nil
+
+	case p.last != nil:
+		p.last[p.lastKey] += "\n" + line
+	}
+}
+
+// setValue sets the "key=value" kv in values
+func (p *instrumentParser) setValue(values map[string]string, kv string) {
+	split := strings.SplitN(kv, "=", 2)
+	if len(split) != 2 {
+		return
+	}
+	values[split[0]] = split[1]
+	p.last, p.lastKey = values, split[0]
+}
+
+func (p *instrumentParser) finish(component string) (InstrumentResult, error) {
+	if p.failure != "" {
+		return InstrumentResult{}, fmt.Errorf("adb instrument %s: %s", component, p.failure)
+	}
+	if p.code == nil {
+		return InstrumentResult{}, fmt.Errorf("adb instrument %s: no result, the instrumentation may have crashed", component)
+	}
+	return InstrumentResult{Code: *p.code, Values: p.result}, nil
+}
@@ -0,0 +1,71 @@
+package gadb
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_instrumentParser(t *testing.T) {
+	output := `INSTRUMENTATION_STATUS: class=com.example.FooTest
+INSTRUMENTATION_STATUS: current=1
+INSTRUMENTATION_STATUS: id=AndroidJUnitRunner
+INSTRUMENTATION_STATUS: numtests=2
+INSTRUMENTATION_STATUS: stream=
+com.example.FooTest:
+INSTRUMENTATION_STATUS: test=testA
+INSTRUMENTATION_STATUS_CODE: 1
+INSTRUMENTATION_STATUS: class=com.example.FooTest
+INSTRUMENTATION_STATUS: current=1
+INSTRUMENTATION_STATUS: stack=java.lang.AssertionError: expected:<1> but was:<2>
+	at com.example.FooTest.testA(FooTest.java:12)
+
+INSTRUMENTATION_STATUS: test=testA
+INSTRUMENTATION_STATUS_CODE: -2
+INSTRUMENTATION_RESULT: stream=
+
+Time: 0.5
+
+FAILURES!!!
+Tests run: 2,  Failures: 1
+
+
+INSTRUMENTATION_CODE: -1`
+
+	var statuses []InstrumentStatus
+	p := newInstrumentParser(func(s InstrumentStatus) {
+		statuses = append(statuses, s)
+	})
+	for _, l := range strings.Split(output, "\n") {
+		p.parseLine(l)
+	}
+
+	result, err := p.finish("com.example.test/androidx.test.runner.AndroidJUnitRunner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Code != -1 || !strings.Contains(result.Values["stream"], "Tests run: 2,  Failures: 1") {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	if len(statuses) != 2 {
+		t.Fatalf("got %d statuses, want 2", len(statuses))
+	}
+	if statuses[0].Code != InstrumentStatusStart || statuses[0].Values["test"] != "testA" || statuses[0].Values["stream"] != "\ncom.example.FooTest:" {
+		t.Errorf("unexpected start status: %+v", statuses[0])
+	}
+	if statuses[1].Code != InstrumentStatusFailure || !strings.HasSuffix(statuses[1].Values["stack"], "at com.example.FooTest.testA(FooTest.java:12)\n") {
+		t.Errorf("unexpected failure status: %+v", statuses[1])
+	}
+}
+
+func Test_instrumentParser_failed(t *testing.T) {
+	p := newInstrumentParser(nil)
+	p.parseLine("INSTRUMENTATION_FAILED: com.example.test/androidx.test.runner.AndroidJUnitRunner")
+	if _, err := p.finish("com.example.test"); err == nil {
+		t.Error("expected error")
+	}
+
+	if _, err := newInstrumentParser(nil).finish("com.example.test"); err == nil {
+		t.Error("expected error without result")
+	}
+}
@@ -0,0 +1,92 @@
+package gadb
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// JDWP returns the pids of the processes of the device that can be debugged
+// through a jdwp:<pid> forward
+func (d Device) JDWP() ([]int, error) {
+	resp, err := d.executeCommand("jdwp")
+	if err != nil {
+		return nil, err
+	}
+	return parsePids(string(resp))
+}
+
+// TrackJDWP streams the pids of the processes of the device that can be
+// debugged, every time they change. The channel is closed when ctx is done
+// or the connection breaks.
+func (d Device) TrackJDWP(ctx context.Context) (<-chan []int, error) {
+	tp, err := d.createDeviceTransport()
+	if err != nil {
+		return nil, err
+	}
+
+	err = tp.Send("track-jdwp")
+	if err != nil {
+		tp.Close()
+		return nil, err
+	}
+
+	err = tp.VerifyResponse()
+	if err != nil {
+		tp.Close()
+		return nil, err
+	}
+
+	// Lists only arrive on changes, so the connection may idle for long
+	tp.readTimeout = 0
+
+	untrack, err := d.adbClient.track(tp.sock)
+	if err != nil {
+		tp.Close()
+		return nil, err
+	}
+
+	pidsChan := make(chan []int)
+	go func() {
+		defer close(pidsChan)
+		defer untrack()
+		defer tp.Close()
+
+		stop := closeOnCancel(ctx, tp)
+		defer stop()
+
+		for {
+			resp, err := tp.UnpackString()
+			if err != nil {
+				return
+			}
+
+			pids, err := parsePids(resp)
+			if err != nil {
+				return
+			}
+
+			select {
+			case pidsChan <- pids:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return pidsChan, nil
+}
+
+// parsePids parses a newline separated list of pids
+func parsePids(resp string) ([]int, error) {
+	pids := []int{}
+	for _, f := range strings.Fields(resp) {
+		pid, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, fmt.Errorf("adb jdwp: invalid pid: %q", f)
+		}
+		pids = append(pids, pid)
+	}
+	return pids, nil
+}
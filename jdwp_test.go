@@ -0,0 +1,51 @@
+package gadb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parsePids(t *testing.T) {
+	pids, err := parsePids("1234\n5678\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1234, 5678}; !reflect.DeepEqual(pids, want) {
+		t.Errorf("parsePids() = %v, want %v", pids, want)
+	}
+
+	pids, err = parsePids("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pids) != 0 {
+		t.Errorf("parsePids() = %v, want empty", pids)
+	}
+
+	_, err = parsePids("12a4\n")
+	if err == nil {
+		t.Error("expected error for invalid pid")
+	}
+}
+
+func TestDevice_JDWP(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	pids, err := devices[0].JDWP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(devices[0].serial, pids)
+}
@@ -0,0 +1,231 @@
+package gadb
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	logcatTimeFormat = "01-02 15:04:05.000"
+)
+
+// LogcatOptions are the options used to filter logcat
+type LogcatOptions struct {
+	// Buffers are the buffers to read, e.g. "main", "system", "crash" (-b)
+	Buffers []string
+	// Filters are tag:priority filter specs, e.g. "ActivityManager:I", "*:S"
+	Filters []string
+	// Format is the output format, e.g. "threadtime", "brief", "long" (-v)
+	Format string
+	// Dump dumps the log and exits instead of streaming it (-d)
+	Dump bool
+	// SinceTime only prints entries since the given time, in the timezone of
+	// the device (-T)
+	SinceTime time.Time
+}
+
+func (o LogcatOptions) args() []string {
+	var args []string
+	for _, b := range o.Buffers {
+		args = append(args, "-b", b)
+	}
+	if o.Format != "" {
+		args = append(args, "-v", o.Format)
+	}
+	if o.Dump {
+		args = append(args, "-d")
+	}
+	if !o.SinceTime.IsZero() {
+		args = append(args, "-T", o.SinceTime.Format(logcatTimeFormat))
+	}
+	return append(args, o.Filters...)
+}
+
+// LogcatContext streams the device logs to dst until ctx is done, then
+// returns nil. Other errors from the stream are returned.
+func (d Device) LogcatContext(ctx context.Context, dst io.Writer) error {
+	return d.LogcatWithOptions(ctx, dst, LogcatOptions{})
+}
+
+// LogcatWithOptions streams logcat filtered by opts to dst until ctx is done,
+// or until the log is dumped when opts.Dump is set.
+func (d Device) LogcatWithOptions(ctx context.Context, dst io.Writer, opts LogcatOptions) error {
+	cmd := shellCommand("logcat", opts.args())
+
+	// logcat may stay quiet for long, ctx bounds the stream instead
+	r, err := d.WithReadTimeout(0).executeCommandStreaming("shell:" + cmd)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	stop := closeOnCancel(ctx, r)
+	defer stop()
+
+	_, err = io.Copy(dst, r)
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// LogcatSince writes to dst the device logs since t, then returns, like
+// logcat -t '<time>'. logcat reads the time in the timezone of the device,
+// t is converted to the timezone of persist.sys.timezone when the host knows
+// it, and used as is otherwise. Either way the clocks of the host and of the
+// device must agree, entries are missed or repeated otherwise.
+func (d Device) LogcatSince(ctx context.Context, t time.Time, dst io.Writer) error {
+	if tz, err := d.GetProp("persist.sys.timezone"); err == nil && tz != "" {
+		if loc, err := time.LoadLocation(tz); err == nil {
+			t = t.In(loc)
+		}
+	}
+	return d.LogcatWithOptions(ctx, dst, LogcatOptions{Dump: true, SinceTime: t})
+}
+
+// LogPriority is the priority of a log entry, as in android.util.Log
+type LogPriority int
+
+// The log priorities, from the lowest
+const (
+	LogVerbose LogPriority = iota + 2
+	LogDebug
+	LogInfo
+	LogWarn
+	LogError
+	LogFatal
+)
+
+const logPriorityLetters = "VDIWEF"
+
+func (p LogPriority) String() string {
+	if p < LogVerbose || p > LogFatal {
+		return "?"
+	}
+	return logPriorityLetters[p-LogVerbose : p-LogVerbose+1]
+}
+
+// LogcatEntry is a log entry of logcat
+type LogcatEntry struct {
+	// Time is the time of the entry in the local timezone of the host, within
+	// the current year as logcat does not print it
+	Time     time.Time
+	PID      int
+	TID      int
+	Priority LogPriority
+	Tag      string
+	// Message holds every line of a multi-line message, separated by \n
+	Message string
+}
+
+// threadtimePattern matches the lines of logcat -v threadtime, e.g.
+// "01-02 15:04:05.123  1234  5678 I Tag     : message"
+var threadtimePattern = regexp.MustCompile(`^(\d\d-\d\d \d\d:\d\d:\d\d\.\d{3})\s+(\d+)\s+(\d+)\s+([VDIWEF])\s+(.*?)\s*: ?(.*)$`)
+
+// LogcatEntries streams the parsed device logs until ctx is done, the channel
+// is closed then, or when the stream fails.
+func (d Device) LogcatEntries(ctx context.Context) (<-chan LogcatEntry, error) {
+	cmd := shellCommand("logcat", LogcatOptions{Format: "threadtime"}.args())
+
+	// logcat may stay quiet for long, ctx bounds the stream instead
+	r, err := d.WithReadTimeout(0).executeCommandStreaming("shell:" + cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(chan LogcatEntry)
+	go func() {
+		defer close(entries)
+		defer r.Close()
+
+		stop := closeOnCancel(ctx, r)
+		defer stop()
+
+		_ = readLogcatEntries(bufio.NewReader(r), time.Now().Year(), func(e LogcatEntry) bool {
+			select {
+			case entries <- e:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}()
+	return entries, nil
+}
+
+// readLogcatEntries parses the lines of logcat -v threadtime read from r and
+// calls emit with each entry until it returns false. logcat prints a header
+// on every line of a multi-line message, consecutive lines sharing the header
+// are merged back into one entry. An entry is only emitted once the next one
+// starts, or no more data is buffered.
+func readLogcatEntries(r *bufio.Reader, year int, emit func(LogcatEntry) bool) error {
+	var pending *LogcatEntry
+	for {
+		line, err := r.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case line == "" || strings.HasPrefix(line, "--------- "):
+			// Buffer markers, e.g. "--------- beginning of main"
+		default:
+			entry, ok := parseThreadtime(line, year)
+			switch {
+			case !ok && pending != nil:
+				pending.Message += "\n" + line
+			case !ok:
+			case pending != nil && sameLogHeader(*pending, entry):
+				pending.Message += "\n" + entry.Message
+			default:
+				if pending != nil && !emit(*pending) {
+					return nil
+				}
+				pending = &entry
+			}
+		}
+
+		if pending != nil && (err != nil || r.Buffered() == 0) {
+			if !emit(*pending) {
+				return nil
+			}
+			pending = nil
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// parseThreadtime parses a line of logcat -v threadtime
+func parseThreadtime(line string, year int) (LogcatEntry, bool) {
+	m := threadtimePattern.FindStringSubmatch(line)
+	if m == nil {
+		return LogcatEntry{}, false
+	}
+
+	t, err := time.ParseInLocation(logcatTimeFormat, m[1], time.Local)
+	if err != nil {
+		return LogcatEntry{}, false
+	}
+	pid, _ := strconv.Atoi(m[2])
+	tid, _ := strconv.Atoi(m[3])
+	return LogcatEntry{
+		Time:     t.AddDate(year-t.Year(), 0, 0),
+		PID:      pid,
+		TID:      tid,
+		Priority: LogVerbose + LogPriority(strings.Index(logPriorityLetters, m[4])),
+		Tag:      m[5],
+		Message:  m[6],
+	}, true
+}
+
+func sameLogHeader(a, b LogcatEntry) bool {
+	return a.Time.Equal(b.Time) && a.PID == b.PID && a.TID == b.TID && a.Priority == b.Priority && a.Tag == b.Tag
+}
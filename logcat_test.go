@@ -0,0 +1,235 @@
+package gadb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogcatOptions_args(t *testing.T) {
+	opts := LogcatOptions{
+		Buffers:   []string{"main", "crash"},
+		Filters:   []string{"ActivityManager:I", "*:S"},
+		Format:    "threadtime",
+		Dump:      true,
+		SinceTime: time.Date(2024, 3, 4, 5, 6, 7, 8e6, time.UTC),
+	}
+
+	want := []string{
+		"-b", "main", "-b", "crash",
+		"-v", "threadtime",
+		"-d",
+		"-T", "03-04 05:06:07.008",
+		"ActivityManager:I", "*:S",
+	}
+	if got := opts.args(); !reflect.DeepEqual(got, want) {
+		t.Errorf("args() = %q, want %q", got, want)
+	}
+
+	if got := (LogcatOptions{}).args(); len(got) != 0 {
+		t.Errorf("args() = %q, want empty", got)
+	}
+}
+
+func TestDevice_LogcatWithOptions_quoting(t *testing.T) {
+	var requests []string
+	d := Device{
+		adbClient: Client{readTimeout: defaultAdbReadTimeout, dial: fakeServer(t, func(request string) string {
+			requests = append(requests, request)
+			return "OKAY"
+		})},
+		serial: "fake",
+	}
+
+	opts := LogcatOptions{
+		Filters:   []string{"*:S", "My Tag:I"},
+		Dump:      true,
+		SinceTime: time.Date(2024, 3, 4, 5, 6, 7, 8e6, time.UTC),
+	}
+	if err := d.LogcatWithOptions(context.Background(), ioutil.Discard, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "shell:logcat -d -T '03-04 05:06:07.008' '*:S' 'My Tag:I'"
+	if len(requests) != 2 || requests[1] != want {
+		t.Errorf("requests = %q, want the transport then %q", requests, want)
+	}
+}
+
+// streamingServer returns a dialer to an in-memory adb server accepting the
+// transport request, then answering the service request with an endless
+// stream of line
+func streamingServer(t *testing.T, line string) DialFunc {
+	return fakeServer(t, func(string) string { return "OKAY" }, func(_ string, conn net.Conn) {
+		for {
+			if _, err := conn.Write([]byte(line)); err != nil {
+				return
+			}
+		}
+	})
+}
+
+type notifyWriter struct {
+	written chan struct{}
+}
+
+func (w notifyWriter) Write(p []byte) (int, error) {
+	select {
+	case w.written <- struct{}{}:
+	default:
+	}
+	return len(p), nil
+}
+
+func TestDevice_LogcatContext_cancel(t *testing.T) {
+	d := Device{
+		adbClient: Client{readTimeout: defaultAdbReadTimeout, dial: streamingServer(t, "I/test: hello\n")},
+		serial:    "fake",
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := notifyWriter{written: make(chan struct{}, 1)}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- d.LogcatContext(ctx, w)
+	}()
+
+	select {
+	case <-w.written:
+	case <-time.After(time.Second):
+		t.Fatal("no log received")
+	}
+	cancel()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Errorf("LogcatContext() = %v, want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("LogcatContext() did not return after cancel")
+	}
+}
+
+func Test_readLogcatEntries(t *testing.T) {
+	output := "--------- beginning of main\r\n" +
+		"01-02 15:04:05.123  1234  5678 I ActivityManager: Start proc 4321\r\n" +
+		"01-02 15:04:05.200  4321  4321 E AndroidRuntime: FATAL EXCEPTION: main\r\n" +
+		"01-02 15:04:05.200  4321  4321 E AndroidRuntime: \tat com.example.Main.run(Main.java:1)\r\n" +
+		"--------- beginning of crash\r\n" +
+		"12-31 23:59:59.999     1     2 D tag with spaces: \r\n"
+
+	var entries []LogcatEntry
+	err := readLogcatEntries(bufio.NewReader(strings.NewReader(output)), 2024, func(e LogcatEntry) bool {
+		entries = append(entries, e)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []LogcatEntry{
+		{
+			Time:     time.Date(2024, 1, 2, 15, 4, 5, 123e6, time.Local),
+			PID:      1234,
+			TID:      5678,
+			Priority: LogInfo,
+			Tag:      "ActivityManager",
+			Message:  "Start proc 4321",
+		},
+		{
+			Time:     time.Date(2024, 1, 2, 15, 4, 5, 200e6, time.Local),
+			PID:      4321,
+			TID:      4321,
+			Priority: LogError,
+			Tag:      "AndroidRuntime",
+			Message:  "FATAL EXCEPTION: main\n\tat com.example.Main.run(Main.java:1)",
+		},
+		{
+			Time:     time.Date(2024, 12, 31, 23, 59, 59, 999e6, time.Local),
+			PID:      1,
+			TID:      2,
+			Priority: LogDebug,
+			Tag:      "tag with spaces",
+		},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("readLogcatEntries() = %+v, want %+v", entries, want)
+	}
+	if LogError.String() != "E" {
+		t.Errorf("LogError.String() = %q", LogError.String())
+	}
+}
+
+func TestDevice_LogcatEntries_cancel(t *testing.T) {
+	d := Device{
+		adbClient: Client{readTimeout: defaultAdbReadTimeout, dial: streamingServer(t, "01-02 15:04:05.123  1234  5678 I test: hello\n")},
+		serial:    "fake",
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	entries, err := d.LogcatEntries(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case e := <-entries:
+		if e.Tag != "test" || e.Message != "hello" {
+			t.Errorf("unexpected entry %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no entry received")
+	}
+	cancel()
+
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-entries:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("entries not closed after cancel")
+		}
+	}
+}
+
+func TestDevice_LogcatSince(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+	d := devices[0]
+
+	since := time.Now()
+	tag := fmt.Sprintf("gadb%d", since.UnixNano())
+	if _, err := d.RunShellCommand("log", "-t", tag, "after"); err != nil {
+		t.Fatal(err)
+	}
+
+	var logs bytes.Buffer
+	if err := d.LogcatSince(context.Background(), since.Add(-time.Second), &logs); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(logs.String(), tag) {
+		t.Errorf("logs since %v miss %s", since, tag)
+	}
+}
@@ -0,0 +1,143 @@
+package gadb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MemInfo is the memory usage reported by dumpsys meminfo, in kilobytes.
+// Fields missing from the report are left to their zero value.
+type MemInfo struct {
+	// NativeHeap and DalvikHeap are the PSS of the heaps
+	NativeHeap int64
+	DalvikHeap int64
+	// TotalPSS is the PSS of the app, or of all the processes for the
+	// system-wide summary
+	TotalPSS int64
+
+	// TotalRAM, FreeRAM, UsedRAM and LostRAM are only set in the system-wide
+	// summary
+	TotalRAM int64
+	FreeRAM  int64
+	UsedRAM  int64
+	LostRAM  int64
+}
+
+// MemInfo returns the memory usage of the running app of the package, or the
+// system-wide summary if packageName is empty. ErrProcessNotFound is returned
+// when the app is not running.
+func (d Device) MemInfo(packageName string) (MemInfo, error) {
+	args := []string{"meminfo"}
+	if packageName != "" {
+		args = append(args, packageName)
+	}
+	output, err := d.RunShellCommand("dumpsys", args...)
+	if err != nil {
+		return MemInfo{}, fmt.Errorf("adb dumpsys meminfo: %w", err)
+	}
+
+	if packageName == "" {
+		return parseSystemMemInfo(output), nil
+	}
+	if strings.Contains(output, "No process found") {
+		return MemInfo{}, fmt.Errorf("adb dumpsys meminfo %s: %w", packageName, ErrProcessNotFound)
+	}
+	return parseAppMemInfo(output), nil
+}
+
+// parseAppMemInfo reads the Pss Total column of the Native Heap, Dalvik Heap
+// and TOTAL rows of dumpsys meminfo <package>
+func parseAppMemInfo(output string) MemInfo {
+	var info MemInfo
+	rows := []struct {
+		label string
+		value *int64
+	}{
+		{"Native Heap", &info.NativeHeap},
+		{"Dalvik Heap", &info.DalvikHeap},
+		{"TOTAL", &info.TotalPSS},
+	}
+
+	for _, l := range strings.Split(output, "\n") {
+		l = strings.TrimSpace(l)
+		for _, row := range rows {
+			// Only the first row counts, the App Summary that follows has
+			// "Native Heap:" and "TOTAL PSS:" rows of its own
+			if *row.value != 0 || !strings.HasPrefix(l, row.label+" ") {
+				continue
+			}
+			fields := strings.Fields(strings.TrimPrefix(l, row.label))
+			n, err := strconv.ParseInt(fields[0], 10, 64)
+			if err == nil {
+				*row.value = n
+			}
+		}
+	}
+	return info
+}
+
+// parseSystemMemInfo reads the "Total PSS by process" and "Total PSS by
+// category" sections and the RAM totals of dumpsys meminfo
+func parseSystemMemInfo(output string) MemInfo {
+	var info MemInfo
+	section := ""
+	for _, l := range strings.Split(output, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" {
+			section = ""
+			continue
+		}
+		if strings.HasPrefix(l, "Total PSS by ") {
+			section = strings.TrimSuffix(strings.TrimPrefix(l, "Total PSS by "), ":")
+			continue
+		}
+
+		kv := strings.SplitN(l, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+
+		switch section {
+		case "process":
+			// "123,456K: system (pid 1234)"
+			info.TotalPSS += parseKilobytes(key)
+			continue
+		case "category":
+			// "123,456K: Native Heap", or just "Native" on older versions
+			switch value {
+			case "Native Heap", "Native":
+				info.NativeHeap = parseKilobytes(key)
+			case "Dalvik Heap", "Dalvik":
+				info.DalvikHeap = parseKilobytes(key)
+			}
+			continue
+		}
+
+		// "Free RAM: 1,234,567K (  123,456K cached pss + ...)"
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+		switch key {
+		case "Total RAM":
+			info.TotalRAM = parseKilobytes(fields[0])
+		case "Free RAM":
+			info.FreeRAM = parseKilobytes(fields[0])
+		case "Used RAM":
+			info.UsedRAM = parseKilobytes(fields[0])
+		case "Lost RAM":
+			info.LostRAM = parseKilobytes(fields[0])
+		}
+	}
+	return info
+}
+
+// parseKilobytes parses a size such as "1,234,567K" or "1234567 kB"
+func parseKilobytes(s string) int64 {
+	s = strings.TrimSpace(s)
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "kB"), "K")
+	n, _ := strconv.ParseInt(strings.ReplaceAll(strings.TrimSpace(s), ",", ""), 10, 64)
+	return n
+}
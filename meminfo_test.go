@@ -0,0 +1,67 @@
+package gadb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseAppMemInfo(t *testing.T) {
+	output := `Applications Memory Usage (in Kilobytes):
+Uptime: 1234567 Realtime: 1234567
+
+** MEMINFO in pid 4321 [com.example.app] **
+                   Pss  Private  Private  SwapPss      Rss     Heap     Heap     Heap
+                 Total    Dirty    Clean    Dirty    Total     Size    Alloc     Free
+                ------   ------   ------   ------   ------   ------   ------   ------
+  Native Heap     8512     8456        0       60    10432    15360    11222     4137
+  Dalvik Heap     3210     3120        0       24     6784     6512     3256     3256
+        Stack      720      720        0        0      728
+        TOTAL    45678    30210     9876      108    98765    21872    14478     7393
+
+ App Summary
+                       Pss(KB)                        Rss(KB)
+                        ------                         ------
+           Java Heap:     5000                          12000
+         Native Heap:     8456                          10432
+           TOTAL PSS:    45678            TOTAL RSS:    98765       TOTAL SWAP PSS:      108
+`
+	want := MemInfo{NativeHeap: 8512, DalvikHeap: 3210, TotalPSS: 45678}
+	if got := parseAppMemInfo(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseAppMemInfo() = %+v, want %+v", got, want)
+	}
+}
+
+func Test_parseSystemMemInfo(t *testing.T) {
+	output := `Applications Memory Usage (in Kilobytes):
+Uptime: 1234567 Realtime: 1234567
+
+Total PSS by process:
+    250,000K: system (pid 1234)
+    100,000K: com.android.systemui (pid 2345 / activities)
+
+Total PSS by OOM adjustment:
+    150,000K: Native
+         50,000K: surfaceflinger (pid 567)
+
+Total PSS by category:
+    120,000K: Dalvik
+     80,000K: Native
+
+Total RAM: 3,758,016K (status normal)
+ Free RAM: 1,234,567K (  123,456K cached pss + 1,111,111K cached kernel)
+ Used RAM: 2,000,000K (1,900,000K used pss +   100,000K kernel)
+ Lost RAM:   523,449K
+`
+	want := MemInfo{
+		NativeHeap: 80000,
+		DalvikHeap: 120000,
+		TotalPSS:   350000,
+		TotalRAM:   3758016,
+		FreeRAM:    1234567,
+		UsedRAM:    2000000,
+		LostRAM:    523449,
+	}
+	if got := parseSystemMemInfo(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseSystemMemInfo() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,106 @@
+package gadb
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MonkeyOptions are the options of Device.Monkey
+type MonkeyOptions struct {
+	// Seed makes the sequence of events reproducible, zero leaves it random
+	Seed int64
+	// Throttle is the delay between two events
+	Throttle time.Duration
+	// IgnoreCrashes and IgnoreTimeouts keep injecting events after a crash or
+	// an ANR, which are still reported
+	IgnoreCrashes  bool
+	IgnoreTimeouts bool
+}
+
+func (o MonkeyOptions) args() []string {
+	var args []string
+	if o.Seed != 0 {
+		args = append(args, "-s", strconv.FormatInt(o.Seed, 10))
+	}
+	if o.Throttle > 0 {
+		args = append(args, "--throttle", strconv.FormatInt(o.Throttle.Milliseconds(), 10))
+	}
+	if o.IgnoreCrashes {
+		args = append(args, "--ignore-crashes")
+	}
+	if o.IgnoreTimeouts {
+		args = append(args, "--ignore-timeouts")
+	}
+	return args
+}
+
+// MonkeyResult is the outcome of a monkey run
+type MonkeyResult struct {
+	// Completed is true if all the events were injected
+	Completed bool
+	// EventsInjected is the number of events injected before monkey stopped
+	EventsInjected int
+	// Crash holds the report of the crash of the application, if any
+	Crash string
+	// ANR holds the report of the application not responding, if any
+	ANR string
+	// Output is the raw output of monkey
+	Output string
+}
+
+// Monkey sends eventCount pseudo-random events to the launcher activities of
+// pkg. A crash of the application is reported in the result, not as an error.
+func (d Device) Monkey(pkg string, eventCount int, opts MonkeyOptions) (MonkeyResult, error) {
+	args := []string{"-p", pkg, "-c", "android.intent.category.LAUNCHER", "-v"}
+	args = append(args, opts.args()...)
+	args = append(args, strconv.Itoa(eventCount))
+
+	// Runs may last long, without output while throttled
+	result, err := d.WithReadTimeout(0).RunShellV2(context.Background(), "monkey", args...)
+	if err != nil {
+		return MonkeyResult{}, fmt.Errorf("adb monkey %s: %w", pkg, err)
+	}
+
+	output := result.Stdout + result.Stderr
+	if strings.Contains(output, "No activities found to run") {
+		return MonkeyResult{}, fmt.Errorf("adb monkey %s: no launcher activity found", pkg)
+	}
+	return parseMonkeyOutput(output), nil
+}
+
+// parseMonkeyOutput parses the verbose output of monkey
+func parseMonkeyOutput(output string) MonkeyResult {
+	result := MonkeyResult{Output: output}
+
+	var crash, anr []string
+	var report *[]string
+	for _, l := range strings.Split(output, "\n") {
+		l = strings.TrimRight(l, "\r")
+
+		switch {
+		case strings.HasPrefix(l, "// CRASH:"):
+			report = &crash
+		case strings.HasPrefix(l, "// NOT RESPONDING:"):
+			report = &anr
+		case !strings.HasPrefix(l, "// "):
+			report = nil
+		}
+		if report != nil {
+			*report = append(*report, strings.TrimPrefix(l, "// "))
+		}
+
+		switch {
+		case strings.HasPrefix(l, "Events injected:"):
+			result.EventsInjected, _ = strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(l, "Events injected:")))
+		case strings.HasPrefix(l, "// Monkey finished"):
+			result.Completed = true
+		}
+	}
+
+	result.Crash = strings.Join(crash, "\n")
+	result.ANR = strings.Join(anr, "\n")
+	return result
+}
@@ -0,0 +1,51 @@
+package gadb
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMonkeyOptions_args(t *testing.T) {
+	opts := MonkeyOptions{Seed: 42, Throttle: 300 * time.Millisecond, IgnoreCrashes: true}
+	want := []string{"-s", "42", "--throttle", "300", "--ignore-crashes"}
+	if got := opts.args(); !reflect.DeepEqual(got, want) {
+		t.Errorf("args() = %q, want %q", got, want)
+	}
+}
+
+func Test_parseMonkeyOutput(t *testing.T) {
+	completed := parseMonkeyOutput(":Monkey: seed=42 count=100\n" +
+		":IncludeCategory: android.intent.category.LAUNCHER\n" +
+		"Events injected: 100\n" +
+		":Sending rotation degree=0, persist=false\n" +
+		"## Network stats: elapsed time=1234ms\n" +
+		"// Monkey finished\n")
+	if !completed.Completed || completed.EventsInjected != 100 || completed.Crash != "" {
+		t.Errorf("unexpected result: %+v", completed)
+	}
+
+	crashed := parseMonkeyOutput(":Monkey: seed=42 count=100\r\n" +
+		"// CRASH: com.example.app (pid 1234)\r\n" +
+		"// Short Msg: java.lang.NullPointerException\r\n" +
+		"// Long Msg: java.lang.NullPointerException: oops\r\n" +
+		"// \tat com.example.app.Main.onClick(Main.java:42)\r\n" +
+		"** Monkey aborted due to error.\r\n" +
+		"Events injected: 37\r\n" +
+		"** System appears to have crashed at event 37 of 100 using seed 42\r\n")
+	if crashed.Completed || crashed.EventsInjected != 37 {
+		t.Errorf("unexpected result: %+v", crashed)
+	}
+	if !strings.HasPrefix(crashed.Crash, "CRASH: com.example.app (pid 1234)\n") ||
+		!strings.HasSuffix(crashed.Crash, "at com.example.app.Main.onClick(Main.java:42)") {
+		t.Errorf("Crash = %q", crashed.Crash)
+	}
+
+	anr := parseMonkeyOutput("// NOT RESPONDING: com.example.app (pid 1234)\n" +
+		"ANR in com.example.app (com.example.app/.Main)\n" +
+		"// Monkey finished\n")
+	if anr.ANR != "NOT RESPONDING: com.example.app (pid 1234)" {
+		t.Errorf("ANR = %q", anr.ANR)
+	}
+}
@@ -0,0 +1,79 @@
+package gadb
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var inetAddrPattern = regexp.MustCompile(`inet (?:addr:)?(\d+\.\d+\.\d+\.\d+)`)
+
+// IPAddresses returns the IPv4 addresses of the network interfaces of the
+// device that have one, keyed by interface name, e.g. "wlan0" or "eth0"
+func (d Device) IPAddresses() (map[string]string, error) {
+	output, err := d.RunShellCommand("ip", "-o", "-f", "inet", "addr", "show")
+	if err != nil {
+		return nil, fmt.Errorf("adb ip: %w", err)
+	}
+	if addrs := parseIPAddr(output); len(addrs) > 0 {
+		return addrs, nil
+	}
+
+	// ifconfig is the only one available on old devices
+	output, err = d.RunShellCommand("ifconfig")
+	if err != nil {
+		return nil, fmt.Errorf("adb ifconfig: %w", err)
+	}
+	return parseIfconfig(output), nil
+}
+
+// parseIPAddr parses the output of ip -o -f inet addr show, e.g.
+// "30: wlan0    inet 192.168.1.5/24 brd 192.168.1.255 scope global wlan0"
+func parseIPAddr(output string) map[string]string {
+	addrs := map[string]string{}
+	for _, l := range strings.Split(output, "\n") {
+		fields := strings.Fields(l)
+		if len(fields) < 4 || fields[2] != "inet" {
+			continue
+		}
+		// Virtual interfaces are named after their peer, e.g. "eth0@if5"
+		name := strings.SplitN(fields[1], "@", 2)[0]
+		addAddress(addrs, name, strings.SplitN(fields[3], "/", 2)[0])
+	}
+	return addrs
+}
+
+// parseIfconfig parses the output of the toolbox ifconfig, e.g.
+// "wlan0: ip 192.168.1.5 mask 255.255.255.0 flags [up broadcast]", and of the
+// busybox and toybox ones printing an "inet addr:192.168.1.5" line in the
+// block of each interface
+func parseIfconfig(output string) map[string]string {
+	addrs := map[string]string{}
+	name := ""
+	for _, l := range strings.Split(output, "\n") {
+		fields := strings.Fields(l)
+		if len(fields) == 0 {
+			continue
+		}
+		if l[0] != ' ' && l[0] != '\t' {
+			name = strings.TrimSuffix(fields[0], ":")
+			if len(fields) >= 3 && fields[1] == "ip" {
+				addAddress(addrs, name, fields[2])
+			}
+			continue
+		}
+		if m := inetAddrPattern.FindStringSubmatch(l); m != nil && name != "" {
+			addAddress(addrs, name, m[1])
+		}
+	}
+	return addrs
+}
+
+// addAddress keeps the first address of an interface, skipping the
+// unassigned 0.0.0.0
+func addAddress(addrs map[string]string, name, addr string) {
+	if _, ok := addrs[name]; ok || addr == "0.0.0.0" {
+		return
+	}
+	addrs[name] = addr
+}
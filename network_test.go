@@ -0,0 +1,60 @@
+package gadb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_inetAddrPattern(t *testing.T) {
+	tests := map[string]string{
+		"30: wlan0: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500 qdisc mq state UP group default qlen 3000\n" +
+			"    inet 192.168.1.23/24 brd 192.168.1.255 scope global wlan0\n": "192.168.1.23",
+		"wlan0     Link encap:Ethernet  HWaddr 00:11:22:33:44:55\n" +
+			"          inet addr:10.0.0.7  Bcast:10.0.0.255  Mask:255.255.255.0\n": "10.0.0.7",
+		"Device \"wlan0\" does not exist.\n": "",
+	}
+
+	for output, want := range tests {
+		var got string
+		if m := inetAddrPattern.FindStringSubmatch(output); m != nil {
+			got = m[1]
+		}
+		if got != want {
+			t.Errorf("inetAddrPattern in %q = %q, want %q", output, got, want)
+		}
+	}
+}
+
+func Test_parseIPAddr(t *testing.T) {
+	output := "1: lo    inet 127.0.0.1/8 scope host lo\\       valid_lft forever preferred_lft forever\n" +
+		"5: eth0@if6    inet 10.0.2.15/24 brd 10.0.2.255 scope global eth0\\       valid_lft forever preferred_lft forever\n" +
+		"30: wlan0    inet 192.168.1.23/24 brd 192.168.1.255 scope global wlan0\\       valid_lft forever preferred_lft forever\n"
+
+	want := map[string]string{"lo": "127.0.0.1", "eth0": "10.0.2.15", "wlan0": "192.168.1.23"}
+	if got := parseIPAddr(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseIPAddr() = %v, want %v", got, want)
+	}
+}
+
+func Test_parseIfconfig(t *testing.T) {
+	tests := map[string]string{
+		"toolbox": "lo: ip 127.0.0.1 mask 255.0.0.0 flags [up loopback running]\r\n" +
+			"rmnet0: ip 0.0.0.0 mask 0.0.0.0 flags [down]\r\n" +
+			"wlan0: ip 192.168.1.23 mask 255.255.255.0 flags [up broadcast running multicast]\r\n",
+		"busybox": "lo        Link encap:Local Loopback\n" +
+			"          inet addr:127.0.0.1  Mask:255.0.0.0\n" +
+			"\n" +
+			"rmnet0    Link encap:UNSPEC\n" +
+			"          UP RUNNING  MTU:1500  Metric:1\n" +
+			"\n" +
+			"wlan0     Link encap:Ethernet  HWaddr 00:11:22:33:44:55\n" +
+			"          inet addr:192.168.1.23  Bcast:192.168.1.255  Mask:255.255.255.0\n",
+	}
+
+	want := map[string]string{"lo": "127.0.0.1", "wlan0": "192.168.1.23"}
+	for name, output := range tests {
+		if got := parseIfconfig(output); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: parseIfconfig() = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+package gadb
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	tempDir = "/data/local/tmp"
+)
+
+// InstallOptions are the options used when installing a package
+type InstallOptions struct {
+	// Reinstall replaces an existing application (-r)
+	Reinstall bool
+	// GrantPermissions grants all runtime permissions (-g)
+	GrantPermissions bool
+	// AllowDowngrade allows a version code downgrade (-d)
+	AllowDowngrade bool
+	// AllowTestPackages allows test packages (-t)
+	AllowTestPackages bool
+	// UserID installs for the user only (--user), nil installs for all users
+	UserID *int
+}
+
+func (o InstallOptions) args() []string {
+	args := userArgs(o.UserID)
+	if o.Reinstall {
+		args = append(args, "-r")
+	}
+	if o.GrantPermissions {
+		args = append(args, "-g")
+	}
+	if o.AllowDowngrade {
+		args = append(args, "-d")
+	}
+	if o.AllowTestPackages {
+		args = append(args, "-t")
+	}
+	return args
+}
+
+// PackageError is returned when the package manager reports a failure,
+// e.g. "Failure [INSTALL_FAILED_ALREADY_EXISTS: ...]"
+type PackageError struct {
+	// Code is the failure code, e.g. INSTALL_FAILED_ALREADY_EXISTS
+	Code string
+	// Message is the raw output of the package manager
+	Message string
+}
+
+func (e *PackageError) Error() string {
+	if e.Code == "" {
+		return "adb pm: " + e.Message
+	}
+	return fmt.Sprintf("adb pm: %s: %s", e.Code, e.Message)
+}
+
+// ErrPackageNotInstalled is returned when the package is not installed on the device
+var ErrPackageNotInstalled = errors.New("package not installed")
+
+var pmFailureRegexp = regexp.MustCompile(`Failure \[([^\]:]+)(?::\s*([^\]]*))?\]`)
+
+// parsePmOutput interprets the Success/Failure output of pm commands
+func parsePmOutput(output string) error {
+	output = strings.TrimSpace(output)
+	for _, l := range strings.Split(output, "\n") {
+		if strings.TrimSpace(l) == "Success" {
+			return nil
+		}
+	}
+
+	if m := pmFailureRegexp.FindStringSubmatch(output); m != nil {
+		return &PackageError{Code: strings.TrimSpace(m[1]), Message: output}
+	}
+	return &PackageError{Message: output}
+}
+
+// Install installs the apk on the device. The apk is pushed to a temporary
+// file under /data/local/tmp which is removed after the installation.
+func (d Device) Install(apk io.Reader, opts InstallOptions) error {
+	remotePath := fmt.Sprintf("%s/gadb-%d.apk", tempDir, time.Now().UnixNano())
+
+	// A push failing partway leaves a partial apk behind
+	defer func() { _, _ = d.RunShellCommand("rm", "-f", remotePath) }()
+
+	err := d.Push(apk, remotePath, time.Now())
+	if err != nil {
+		return fmt.Errorf("adb install: failed to push apk: %w", err)
+	}
+
+	args := append([]string{"install"}, opts.args()...)
+	args = append(args, remotePath)
+
+	output, err := d.RunShellCommand("pm", args...)
+	if err != nil {
+		return fmt.Errorf("adb install: %w", err)
+	}
+	return parsePmOutput(output)
+}
+
+// UninstallOptions are the options used when uninstalling a package
+type UninstallOptions struct {
+	// KeepData keeps the data and cache directories of the package (-k)
+	KeepData bool
+	// UserID uninstalls for the user only (--user), nil uninstalls for all
+	// users
+	UserID *int
+}
+
+func (o UninstallOptions) args() []string {
+	args := userArgs(o.UserID)
+	if o.KeepData {
+		args = append(args, "-k")
+	}
+	return args
+}
+
+// Uninstall removes the package from the device, optionally keeping its data
+// and cache directories. ErrPackageNotInstalled is returned if the package is
+// not installed.
+func (d Device) Uninstall(packageName string, keepData bool) error {
+	return d.UninstallWithOptions(packageName, UninstallOptions{KeepData: keepData})
+}
+
+// UninstallWithOptions removes the package from the device as Uninstall
+// does, with opts
+func (d Device) UninstallWithOptions(packageName string, opts UninstallOptions) error {
+	args := append([]string{"uninstall"}, opts.args()...)
+	args = append(args, packageName)
+
+	output, err := d.RunShellCommand("pm", args...)
+	if err != nil {
+		return fmt.Errorf("adb uninstall: %w", err)
+	}
+
+	err = parsePmOutput(output)
+	var pErr *PackageError
+	if errors.As(err, &pErr) && isNotInstalled(pErr) {
+		return fmt.Errorf("adb uninstall %s: %w", packageName, ErrPackageNotInstalled)
+	}
+	return err
+}
+
+// ForceStop stops every process of the package
+func (d Device) ForceStop(packageName string) error {
+	output, err := d.RunShellCommand("am", "force-stop", packageName)
+	if err != nil {
+		return fmt.Errorf("adb force-stop %s: %w", packageName, err)
+	}
+	if msg := amError(output); msg != "" {
+		return fmt.Errorf("adb force-stop %s: %s", packageName, msg)
+	}
+	return nil
+}
+
+// ClearData deletes all the data of the package, like a fresh install.
+// ErrPackageNotInstalled is returned if the package is not installed.
+func (d Device) ClearData(packageName string) error {
+	return d.clearData(packageName, nil)
+}
+
+// ClearDataForUser deletes the data of the package for the user only, as
+// ClearData does
+func (d Device) ClearDataForUser(packageName string, userID int) error {
+	return d.clearData(packageName, &userID)
+}
+
+func (d Device) clearData(packageName string, userID *int) error {
+	args := append([]string{"clear"}, userArgs(userID)...)
+	output, err := d.RunShellCommand("pm", append(args, packageName)...)
+	if err != nil {
+		return fmt.Errorf("adb pm clear: %w", err)
+	}
+
+	err = parsePmOutput(output)
+	var pErr *PackageError
+	if !errors.As(err, &pErr) {
+		return err
+	}
+
+	// Older releases only print "Failed", whatever the reason
+	if isNotInstalled(pErr) || !d.isInstalled(packageName, userID) {
+		return fmt.Errorf("adb pm clear %s: %w", packageName, ErrPackageNotInstalled)
+	}
+	return err
+}
+
+// ErrComponentStateDenied is returned when the shell is not allowed to change
+// the enabled state of a package or component, e.g. a protected system one
+var ErrComponentStateDenied = errors.New("can't change component state")
+
+// EnablePackage enables the package, undoing DisablePackage
+func (d Device) EnablePackage(packageName string) error {
+	return d.setEnabledState("enable", packageName)
+}
+
+// DisablePackage disables the package, which then cannot run nor show up in
+// the launcher. pm disable is refused to the shell on user builds, pm
+// disable-user is used there instead, for the current user.
+func (d Device) DisablePackage(packageName string) error {
+	err := d.setEnabledState("disable", packageName)
+	if !errors.Is(err, ErrComponentStateDenied) {
+		return err
+	}
+
+	userID, err := d.CurrentUser()
+	if err != nil {
+		return err
+	}
+	return d.setEnabledState("disable-user", append(userArgs(&userID), packageName)...)
+}
+
+// SetComponentEnabled enables or disables the component, e.g.
+// "com.example/.MainActivity", like EnablePackage and DisablePackage
+func (d Device) SetComponentEnabled(component string, enabled bool) error {
+	if enabled {
+		return d.EnablePackage(component)
+	}
+	return d.DisablePackage(component)
+}
+
+// setEnabledState runs pm enable, disable or disable-user, which report
+// "Package <name> new state: <state>" on success
+func (d Device) setEnabledState(command string, args ...string) error {
+	target := args[len(args)-1]
+	output, err := d.RunShellCommand("pm", append([]string{command}, args...)...)
+	if err != nil {
+		return fmt.Errorf("adb pm %s: %w", command, err)
+	}
+	return enabledStateError(command, target, output)
+}
+
+// enabledStateError returns the error reported by pm enable or disable, if any
+func enabledStateError(command, target, output string) error {
+	switch {
+	case strings.Contains(output, "new state:"):
+		return nil
+	case strings.Contains(output, "Unknown package") || strings.Contains(output, "Unknown component"):
+		return fmt.Errorf("adb pm %s %s: %w", command, target, ErrPackageNotInstalled)
+	case strings.Contains(output, "SecurityException") || strings.Contains(output, "change component state"):
+		// The exception line tells why, e.g. "Shell cannot change component
+		// state for <name> to 2"
+		reason := amError(output)
+		for _, l := range strings.Split(output, "\n") {
+			if strings.Contains(l, "SecurityException") {
+				reason = strings.TrimSpace(l)
+			}
+		}
+		return fmt.Errorf("adb pm %s %s: %w: %s", command, target, ErrComponentStateDenied, reason)
+	}
+	msg := amError(output)
+	if msg == "" {
+		msg = strings.TrimSpace(output)
+	}
+	return fmt.Errorf("adb pm %s %s: %s", command, target, msg)
+}
+
+// isInstalled reports whether the package is installed, according to pm path
+func (d Device) isInstalled(packageName string, userID *int) bool {
+	args := append([]string{"path"}, userArgs(userID)...)
+	output, err := d.RunShellCommand("pm", append(args, packageName)...)
+	return err == nil && strings.HasPrefix(strings.TrimSpace(output), "package:")
+}
+
+// isNotInstalled reports whether the failure is due to a missing package.
+// Older releases report DELETE_FAILED_INTERNAL_ERROR, newer ones
+// "not installed for <user>".
+func isNotInstalled(err *PackageError) bool {
+	return err.Code == "DELETE_FAILED_INTERNAL_ERROR" ||
+		strings.HasPrefix(err.Code, "not installed for") ||
+		strings.Contains(err.Message, "Unknown package")
+}
+
+// PackageFilter are the options used when listing packages
+type PackageFilter struct {
+	// OnlyEnabled only lists enabled packages (-e)
+	OnlyEnabled bool
+	// OnlyDisabled only lists disabled packages (-d)
+	OnlyDisabled bool
+	// ThirdPartyOnly only lists third party packages (-3)
+	ThirdPartyOnly bool
+	// SystemOnly only lists system packages (-s)
+	SystemOnly bool
+	// IncludePath includes the path of the APK of each package (-f)
+	IncludePath bool
+	// UserID only lists the packages of the user (--user), nil lists those
+	// of all users
+	UserID *int
+}
+
+func (f PackageFilter) args() []string {
+	args := userArgs(f.UserID)
+	if f.OnlyEnabled {
+		args = append(args, "-e")
+	}
+	if f.OnlyDisabled {
+		args = append(args, "-d")
+	}
+	if f.ThirdPartyOnly {
+		args = append(args, "-3")
+	}
+	if f.SystemOnly {
+		args = append(args, "-s")
+	}
+	if f.IncludePath {
+		args = append(args, "-f")
+	}
+	return args
+}
+
+// PackageInfo is a package installed on the device
+type PackageInfo struct {
+	PackageName string
+	// Path is the path of the APK, only set with PackageFilter.IncludePath
+	Path string
+}
+
+// ListPackages returns the packages installed on the device matching the filter
+func (d Device) ListPackages(opts PackageFilter) ([]PackageInfo, error) {
+	args := append([]string{"list", "packages"}, opts.args()...)
+
+	output, err := d.RunShellCommand("pm", args...)
+	if err != nil {
+		return nil, fmt.Errorf("adb pm list packages: %w", err)
+	}
+	return parsePackageList(output, opts.IncludePath)
+}
+
+// parsePackageList parses the "package:<name>" or, with paths,
+// "package:<path>=<name>" lines of pm list packages
+func parsePackageList(output string, withPath bool) ([]PackageInfo, error) {
+	var packages []PackageInfo
+	for _, l := range strings.Split(output, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" {
+			continue
+		}
+		if !strings.HasPrefix(l, "package:") {
+			return nil, fmt.Errorf("adb pm list packages: %s", l)
+		}
+		l = strings.TrimPrefix(l, "package:")
+
+		var info PackageInfo
+		// The path itself may contain '=', the name never does
+		if i := strings.LastIndex(l, "="); withPath && i >= 0 {
+			info.Path = l[:i]
+			info.PackageName = l[i+1:]
+		} else {
+			info.PackageName = l
+		}
+		packages = append(packages, info)
+	}
+	return packages, nil
+}
@@ -0,0 +1,229 @@
+package gadb
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_parsePmOutput(t *testing.T) {
+	tests := []struct {
+		output string
+		code   string
+		ok     bool
+	}{
+		{output: "Success\n", ok: true},
+		{output: "Performing Streamed Install\nSuccess\n", ok: true},
+		{output: "Failure [INSTALL_FAILED_ALREADY_EXISTS: Attempt to re-install com.example without first uninstalling.]\n", code: "INSTALL_FAILED_ALREADY_EXISTS"},
+		{output: "Failure [DELETE_FAILED_INTERNAL_ERROR]\n", code: "DELETE_FAILED_INTERNAL_ERROR"},
+		{output: "Error: java.lang.SecurityException\n"},
+	}
+
+	for _, tt := range tests {
+		err := parsePmOutput(tt.output)
+		if tt.ok {
+			if err != nil {
+				t.Errorf("parsePmOutput(%q) = %v, want nil", tt.output, err)
+			}
+			continue
+		}
+
+		var pErr *PackageError
+		if !errors.As(err, &pErr) {
+			t.Errorf("parsePmOutput(%q) = %v, want *PackageError", tt.output, err)
+			continue
+		}
+		if pErr.Code != tt.code {
+			t.Errorf("parsePmOutput(%q) code = %q, want %q", tt.output, pErr.Code, tt.code)
+		}
+	}
+}
+
+func Test_isNotInstalled(t *testing.T) {
+	for _, output := range []string{
+		"Failure [DELETE_FAILED_INTERNAL_ERROR]",
+		"Failure [not installed for 0]",
+	} {
+		var pErr *PackageError
+		if !errors.As(parsePmOutput(output), &pErr) || !isNotInstalled(pErr) {
+			t.Errorf("isNotInstalled(%q) = false, want true", output)
+		}
+	}
+
+	var pErr *PackageError
+	if errors.As(parsePmOutput("Failure [DELETE_FAILED_DEVICE_POLICY_MANAGER]"), &pErr) && isNotInstalled(pErr) {
+		t.Error("isNotInstalled(DELETE_FAILED_DEVICE_POLICY_MANAGER) = true, want false")
+	}
+}
+
+func Test_parsePackageList(t *testing.T) {
+	output := "package:/data/app/~~Xy1w==/com.example-Ab3==/base.apk=com.example\r\n" +
+		"package:/system/app/Settings/Settings.apk=com.android.settings\n"
+
+	got, err := parsePackageList(output, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []PackageInfo{
+		{PackageName: "com.example", Path: "/data/app/~~Xy1w==/com.example-Ab3==/base.apk"},
+		{PackageName: "com.android.settings", Path: "/system/app/Settings/Settings.apk"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parsePackageList() = %v, want %v", got, want)
+	}
+
+	got, err = parsePackageList("package:com.example\npackage:com.android.settings\n", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []PackageInfo{{PackageName: "com.example"}, {PackageName: "com.android.settings"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parsePackageList() = %v, want %v", got, want)
+	}
+}
+
+func TestDevice_ListPackages(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	packages, err := devices[0].ListPackages(PackageFilter{ThirdPartyOnly: true, IncludePath: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range packages {
+		t.Log(p.PackageName, p.Path)
+	}
+}
+
+func TestDevice_ClearData_notInstalled(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	err = devices[0].ClearData("com.example.does.not.exist")
+	if !errors.Is(err, ErrPackageNotInstalled) {
+		t.Errorf("ClearData() = %v, want ErrPackageNotInstalled", err)
+	}
+	if err := devices[0].ForceStop("com.android.settings"); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_enabledStateError(t *testing.T) {
+	tests := []struct {
+		output string
+		want   error
+	}{
+		{output: "Package com.example new state: disabled-user\n"},
+		{
+			output: "Exception occurred while executing 'disable':\n" +
+				"java.lang.SecurityException: Shell cannot change component state for com.example to 2\n",
+			want: ErrComponentStateDenied,
+		},
+		{
+			output: "Error: java.lang.IllegalArgumentException: Unknown package: com.missing\n",
+			want:   ErrPackageNotInstalled,
+		},
+	}
+
+	for _, tt := range tests {
+		err := enabledStateError("disable", "com.example", tt.output)
+		if tt.want == nil && err != nil || !errors.Is(err, tt.want) {
+			t.Errorf("enabledStateError(%q) = %v, want %v", tt.output, err, tt.want)
+		}
+	}
+}
+
+func TestInstallOptions_args(t *testing.T) {
+	user := 0
+	opts := InstallOptions{Reinstall: true, GrantPermissions: true, UserID: &user}
+
+	want := []string{"--user", "0", "-r", "-g"}
+	if got := opts.args(); !reflect.DeepEqual(got, want) {
+		t.Errorf("args() = %q, want %q", got, want)
+	}
+	if got := (InstallOptions{}).args(); len(got) != 0 {
+		t.Errorf("args() = %q, want empty", got)
+	}
+}
+
+func TestUninstallOptions_args(t *testing.T) {
+	user := 10
+	opts := UninstallOptions{KeepData: true, UserID: &user}
+
+	want := []string{"--user", "10", "-k"}
+	if got := opts.args(); !reflect.DeepEqual(got, want) {
+		t.Errorf("args() = %q, want %q", got, want)
+	}
+	if got := (UninstallOptions{}).args(); len(got) != 0 {
+		t.Errorf("args() = %q, want empty", got)
+	}
+}
+
+func TestDevice_DisablePackage_currentUser(t *testing.T) {
+	var commands []string
+	d := Device{
+		adbClient: Client{readTimeout: defaultAdbReadTimeout, dial: shellServer(t, func(cmd string) string {
+			commands = append(commands, cmd)
+			switch cmd {
+			case "pm disable com.example":
+				return "Error: java.lang.SecurityException: Shell cannot change component state for com.example to 2\n"
+			case "am get-current-user":
+				return "10\n"
+			case "pm disable-user --user 10 com.example":
+				return "Package com.example new state: disabled-user\n"
+			}
+			return ""
+		})},
+		serial: "fake",
+	}
+
+	if err := d.DisablePackage("com.example"); err != nil {
+		t.Fatal(err)
+	}
+	if last := commands[len(commands)-1]; last != "pm disable-user --user 10 com.example" {
+		t.Errorf("last command = %q, want disable-user for the current user", last)
+	}
+}
+
+func TestDevice_Install_pushFailure(t *testing.T) {
+	// The fake sync server drops the connection on SEND
+	var commands []string
+	d := Device{
+		adbClient: Client{readTimeout: defaultAdbReadTimeout, dial: syncServer(t, nil, func(cmd string) string {
+			commands = append(commands, cmd)
+			return ""
+		})},
+		serial: "fake",
+	}
+
+	if err := d.Install(strings.NewReader("apk"), InstallOptions{}); err == nil {
+		t.Fatal("expected error")
+	}
+	if len(commands) != 1 || !strings.HasPrefix(commands[0], "rm -f "+tempDir+"/gadb-") {
+		t.Errorf("commands = %q, want the partial apk removed", commands)
+	}
+}
@@ -0,0 +1,117 @@
+package gadb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GrantPermission grants a runtime permission to the package, e.g.
+// android.permission.CAMERA
+func (d Device) GrantPermission(packageName, permission string) error {
+	return d.pmPermission("grant", packageName, permission)
+}
+
+// RevokePermission revokes a runtime permission from the package
+func (d Device) RevokePermission(packageName, permission string) error {
+	return d.pmPermission("revoke", packageName, permission)
+}
+
+func (d Device) pmPermission(command, packageName, permission string) error {
+	output, err := d.RunShellCommand("pm", command, packageName, permission)
+	if err != nil {
+		return fmt.Errorf("adb pm %s: %w", command, err)
+	}
+	// Nothing is printed on success
+	if output = strings.TrimSpace(output); output != "" {
+		return fmt.Errorf("adb pm %s %s %s: %s", command, packageName, permission, output)
+	}
+	return nil
+}
+
+// GrantAllRuntimePermissions grants to the package all the runtime
+// permissions it requests and does not hold yet, so no permission dialog
+// shows up. Devices before Android 6 grant them on install. Permissions that
+// cannot be granted are reported as ErrWarnings.
+func (d Device) GrantAllRuntimePermissions(packageName string) error {
+	output, err := d.RunShellCommand("dumpsys", "package", packageName)
+	if err != nil {
+		return fmt.Errorf("adb dumpsys package: %w", err)
+	}
+
+	var warnings []string
+	for _, permission := range parseRuntimePermissions(output) {
+		err = d.GrantPermission(packageName, permission)
+		// Requested permissions include install time ones, pm grant refuses
+		// them
+		if err != nil && !strings.Contains(err.Error(), "not a changeable permission type") {
+			warnings = append(warnings, err.Error())
+		}
+	}
+
+	if len(warnings) > 0 {
+		return ErrWarnings(warnings)
+	}
+	return nil
+}
+
+// parseRuntimePermissions returns the permissions of the "requested
+// permissions:" section of dumpsys package that may need to be granted:
+// those neither listed as install permissions nor granted in all the
+// "runtime permissions:" sections. Android 6 to 9 leave ungranted runtime
+// permissions without flags out of the latter.
+func parseRuntimePermissions(output string) []string {
+	var requested []string
+	install := map[string]bool{}
+	granted := map[string]bool{}
+	denied := map[string]bool{}
+
+	section, sectionIndent := "", -1
+	for _, l := range strings.Split(output, "\n") {
+		l = strings.TrimRight(l, "\r")
+		trimmed := strings.TrimSpace(l)
+		indent := len(l) - len(strings.TrimLeft(l, " "))
+
+		switch trimmed {
+		case "requested permissions:", "install permissions:", "runtime permissions:":
+			section, sectionIndent = trimmed, indent
+			continue
+		}
+		if sectionIndent < 0 {
+			continue
+		}
+		if trimmed == "" || indent <= sectionIndent {
+			section, sectionIndent = "", -1
+			continue
+		}
+
+		// android.permission.CAMERA: granted=false, flags=[ ... ], or only
+		// the name, possibly followed by ", restricted=true", when requested
+		name := trimmed
+		if i := strings.IndexAny(name, ":,"); i >= 0 {
+			name = name[:i]
+		}
+		switch section {
+		case "requested permissions:":
+			requested = append(requested, name)
+		case "install permissions:":
+			install[name] = true
+		case "runtime permissions:":
+			if strings.Contains(trimmed, "granted=true") {
+				granted[name] = true
+			} else {
+				denied[name] = true
+			}
+		}
+	}
+
+	var permissions []string
+	seen := map[string]bool{}
+	for _, name := range requested {
+		if seen[name] || install[name] || granted[name] && !denied[name] {
+			continue
+		}
+		seen[name] = true
+		permissions = append(permissions, name)
+	}
+	return permissions
+}
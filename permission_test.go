@@ -0,0 +1,89 @@
+package gadb
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_parseRuntimePermissions(t *testing.T) {
+	output := `Packages:
+  Package [com.example.app] (5b0c3a1):
+    userId=10123
+    requested permissions:
+      android.permission.INTERNET
+      android.permission.CAMERA
+      android.permission.ACCESS_FINE_LOCATION
+      android.permission.READ_CONTACTS
+    install permissions:
+      android.permission.INTERNET: granted=true
+    User 0: ceDataInode=12345 installed=true hidden=false
+      gids=[3003]
+      runtime permissions:
+        android.permission.CAMERA: granted=false, flags=[ USER_SENSITIVE_WHEN_GRANTED|USER_SENSITIVE_WHEN_DENIED]
+        android.permission.ACCESS_FINE_LOCATION: granted=false, flags=[ USER_SENSITIVE_WHEN_GRANTED]
+        android.permission.READ_CONTACTS: granted=true, flags=[ USER_SET]
+      enabledComponents:
+        com.example.app.CAMERA: granted=false
+    User 10: ceDataInode=0 installed=true hidden=false
+      runtime permissions:
+        android.permission.CAMERA: granted=false
+`
+
+	want := []string{"android.permission.CAMERA", "android.permission.ACCESS_FINE_LOCATION"}
+	if got := parseRuntimePermissions(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseRuntimePermissions() = %q, want %q", got, want)
+	}
+
+	// Android 6 to 9 only list the runtime permissions granted or with flags
+	output = `Packages:
+  Package [com.example.app] (5b0c3a1):
+    requested permissions:
+      android.permission.INTERNET
+      android.permission.CAMERA
+      android.permission.READ_CONTACTS
+    install permissions:
+      android.permission.INTERNET: granted=true
+    User 0: ceDataInode=12345 installed=true hidden=false
+      runtime permissions:
+        android.permission.READ_CONTACTS: granted=true, flags=[ USER_SET ]
+`
+	want = []string{"android.permission.CAMERA"}
+	if got := parseRuntimePermissions(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseRuntimePermissions() = %q, want %q", got, want)
+	}
+
+	if got := parseRuntimePermissions("Unable to find package: com.nope\n"); len(got) != 0 {
+		t.Errorf("parseRuntimePermissions() = %q, want none", got)
+	}
+}
+
+func TestDevice_GrantAllRuntimePermissions(t *testing.T) {
+	dumpsys := `    requested permissions:
+      android.permission.ACCESS_NETWORK_STATE
+      android.permission.CAMERA
+`
+	var grants []string
+	d := Device{
+		adbClient: Client{readTimeout: defaultAdbReadTimeout, dial: shellServer(t, func(cmd string) string {
+			switch {
+			case cmd == "dumpsys package com.example":
+				return dumpsys
+			case strings.HasPrefix(cmd, "pm grant "):
+				grants = append(grants, cmd)
+				if strings.HasSuffix(cmd, "ACCESS_NETWORK_STATE") {
+					return "Exception occurred while executing 'grant':\njava.lang.SecurityException: Permission android.permission.ACCESS_NETWORK_STATE requested by com.example is not a changeable permission type\n"
+				}
+			}
+			return ""
+		})},
+		serial: "fake",
+	}
+
+	if err := d.GrantAllRuntimePermissions("com.example"); err != nil {
+		t.Fatal(err)
+	}
+	if len(grants) != 2 {
+		t.Errorf("granted %q, want both requested permissions", grants)
+	}
+}
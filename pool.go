@@ -0,0 +1,136 @@
+package gadb
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// poolIdleTimeout bounds the age of an idle connection
+	poolIdleTimeout = 30 * time.Second
+
+	// poolProbeTimeout is how long get waits for a closed idle connection
+	// to report EOF
+	poolProbeTimeout = time.Millisecond
+)
+
+// connPool keeps connections to the adb server dialed ahead of time.
+// The adb server closes a connection once its request is served, so
+// connections are never given back: the pool is refilled in the background
+// instead, sparing the dial to the following requests.
+type connPool struct {
+	dial func() (net.Conn, error)
+	size int
+
+	mu      sync.Mutex
+	idle    []pooledConn
+	filling bool
+	closed  bool
+	// gen is bumped by reset, connections dialed before are discarded
+	gen int
+}
+
+type pooledConn struct {
+	net.Conn
+	dialed time.Time
+}
+
+func newConnPool(size int, dial func() (net.Conn, error)) *connPool {
+	return &connPool{dial: dial, size: size}
+}
+
+// get returns an idle connection, or dials a new one if there is none.
+// Idle connections closed by the server, e.g. when it was restarted, are
+// discarded.
+func (p *connPool) get() (net.Conn, error) {
+	defer p.refill()
+
+	for {
+		p.mu.Lock()
+		if len(p.idle) == 0 {
+			p.mu.Unlock()
+			return p.dial()
+		}
+		pc := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		p.mu.Unlock()
+
+		if time.Since(pc.dialed) < poolIdleTimeout && pc.alive() {
+			return pc.Conn, nil
+		}
+		_ = pc.Close()
+	}
+}
+
+// alive reports whether the connection is still open. The server never
+// writes before it gets a request, so a read times out unless it closed it.
+func (pc pooledConn) alive() bool {
+	if err := pc.SetReadDeadline(time.Now().Add(poolProbeTimeout)); err != nil {
+		return false
+	}
+	_, err := pc.Read(make([]byte, 1))
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		return false
+	}
+	return pc.SetReadDeadline(time.Time{}) == nil
+}
+
+// refill dials connections in the background until the pool is full, or
+// a dial fails
+func (p *connPool) refill() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.filling || p.closed || len(p.idle) >= p.size {
+		return
+	}
+	p.filling = true
+	gen := p.gen
+
+	go func() {
+		for {
+			conn, err := p.dial()
+
+			p.mu.Lock()
+			stale := p.closed || p.gen != gen
+			if err == nil && stale {
+				_ = conn.Close()
+			} else if err == nil {
+				p.idle = append(p.idle, pooledConn{Conn: conn, dialed: time.Now()})
+			}
+			if err != nil || stale || len(p.idle) >= p.size {
+				p.filling = false
+				p.mu.Unlock()
+				return
+			}
+			p.mu.Unlock()
+		}
+	}()
+}
+
+// close closes the idle connections and stops refilling the pool
+func (p *connPool) close() error {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	return p.reset()
+}
+
+// reset closes the idle connections and discards the ones being dialed, for
+// when the server goes away
+func (p *connPool) reset() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.gen++
+	var err error
+	for _, pc := range p.idle {
+		if closeErr := pc.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	p.idle = nil
+	return err
+}
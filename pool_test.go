@@ -0,0 +1,166 @@
+package gadb
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConnPool(t *testing.T) {
+	var dials int32
+	var mu sync.Mutex
+	var conns []net.Conn
+	pool := newConnPool(2, func() (net.Conn, error) {
+		atomic.AddInt32(&dials, 1)
+		client, server := net.Pipe()
+		mu.Lock()
+		conns = append(conns, client, server)
+		mu.Unlock()
+		return client, nil
+	})
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+
+	idle := func() int {
+		pool.mu.Lock()
+		defer pool.mu.Unlock()
+		return len(pool.idle)
+	}
+	waitFull := func() {
+		deadline := time.Now().Add(time.Second)
+		for idle() < 2 {
+			if time.Now().After(deadline) {
+				t.Fatalf("pool not refilled, %d idle", idle())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	if _, err := pool.get(); err != nil {
+		t.Fatal(err)
+	}
+	waitFull()
+	if n := atomic.LoadInt32(&dials); n != 3 {
+		t.Errorf("dials = %d, want 3", n)
+	}
+
+	// Served from the pool, then refilled
+	if _, err := pool.get(); err != nil {
+		t.Fatal(err)
+	}
+	waitFull()
+	if n := atomic.LoadInt32(&dials); n != 4 {
+		t.Errorf("dials = %d, want 4", n)
+	}
+}
+
+func TestConnPool_stale(t *testing.T) {
+	var mu sync.Mutex
+	var servers []net.Conn
+	pool := newConnPool(2, func() (net.Conn, error) {
+		client, server := net.Pipe()
+		mu.Lock()
+		servers = append(servers, server)
+		mu.Unlock()
+		return client, nil
+	})
+	defer pool.close()
+
+	idle := func() int {
+		pool.mu.Lock()
+		defer pool.mu.Unlock()
+		return len(pool.idle)
+	}
+	waitFull := func() {
+		deadline := time.Now().Add(time.Second)
+		for idle() < 2 {
+			if time.Now().After(deadline) {
+				t.Fatalf("pool not refilled, %d idle", idle())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	conn, err := pool.get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	waitFull()
+
+	// The server went away, closing the idle connections
+	mu.Lock()
+	for _, server := range servers {
+		server.Close()
+	}
+	dialed := len(servers)
+	mu.Unlock()
+
+	conn, err = pool.get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	mu.Lock()
+	fresh := servers[dialed]
+	mu.Unlock()
+	go func() { _, _ = fresh.Write([]byte("OKAY")) }()
+	if err := conn.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Read(make([]byte, 4)); err != nil {
+		t.Errorf("get() returned a dead connection: %v", err)
+	}
+
+	if err := pool.reset(); err != nil {
+		t.Fatal(err)
+	}
+	if n := idle(); n != 0 {
+		t.Errorf("%d idle connections after reset, want 0", n)
+	}
+}
+
+func benchmarkConcurrentGetProp(b *testing.B, opts ...ClientOption) {
+	c, err := NewClient(opts...)
+	if err != nil {
+		b.Skip(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		b.SkipNow()
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var wg sync.WaitGroup
+		for j := 0; j < 100; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := devices[0].RunShellCommand("getprop", "ro.build.version.sdk")
+				if err != nil {
+					b.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+func BenchmarkClient_concurrentGetProp(b *testing.B) {
+	benchmarkConcurrentGetProp(b)
+}
+
+func BenchmarkClient_concurrentGetPropNoPool(b *testing.B) {
+	benchmarkConcurrentGetProp(b, WithPoolSize(0))
+}
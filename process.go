@@ -0,0 +1,134 @@
+package gadb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ErrProcessNotFound is returned by KillByName when no process has the name
+var ErrProcessNotFound = errors.New("process not found")
+
+// ProcessInfo is a process running on the device
+type ProcessInfo struct {
+	PID  int
+	PPID int
+	// RSS is the resident set size in kilobytes
+	RSS  int64
+	Name string
+}
+
+// Processes returns the processes running on the device
+func (d Device) Processes() ([]ProcessInfo, error) {
+	output, err := d.RunShellCommand("ps", "-A", "-o", "PID,PPID,RSS,NAME")
+	if err != nil {
+		return nil, fmt.Errorf("adb ps: %w", err)
+	}
+	processes, err := parseProcesses(output)
+	if err == nil && len(processes) > 0 {
+		return processes, nil
+	}
+
+	// Toolbox before Android 8 supports neither -A nor -o, and lists all
+	// the processes by default
+	output, err = d.RunShellCommand("ps")
+	if err != nil {
+		return nil, fmt.Errorf("adb ps: %w", err)
+	}
+	return parseProcesses(output)
+}
+
+// parseProcesses parses the output of ps, mapping the columns by the names
+// of the header
+func parseProcesses(output string) ([]ProcessInfo, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	header := strings.Fields(lines[0])
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[name] = i
+	}
+	pidCol, ok := columns["PID"]
+	if !ok {
+		return nil, errors.New("adb ps: no PID column")
+	}
+	ppidCol, hasPPID := columns["PPID"]
+	rssCol, hasRSS := columns["RSS"]
+	if !hasRSS {
+		rssCol, hasRSS = columns["RES"]
+	}
+
+	var processes []ProcessInfo
+	for _, l := range lines[1:] {
+		fields := strings.Fields(l)
+		if len(fields) < len(header) {
+			continue
+		}
+
+		pid, err := strconv.Atoi(fields[pidCol])
+		if err != nil {
+			continue
+		}
+		// The name is last, toolbox also inserts an unnamed state column
+		// before it
+		p := ProcessInfo{PID: pid, Name: fields[len(fields)-1]}
+		if hasPPID {
+			p.PPID, _ = strconv.Atoi(fields[ppidCol])
+		}
+		if hasRSS {
+			p.RSS, _ = strconv.ParseInt(fields[rssCol], 10, 64)
+		}
+		processes = append(processes, p)
+	}
+	return processes, nil
+}
+
+// KillPID sends the signal to the process. Without root only the processes of
+// the shell user can be signaled, the error then holds the output of kill,
+// e.g. "Operation not permitted"
+func (d Device) KillPID(pid int, signal syscall.Signal) error {
+	result, err := d.RunShellV2(context.Background(), "kill", fmt.Sprintf("-%d", int(signal)), strconv.Itoa(pid))
+	if err != nil {
+		return fmt.Errorf("adb kill %d: %w", pid, err)
+	}
+
+	if msg := result.failure(); msg != "" {
+		return fmt.Errorf("adb kill %d: %s", pid, msg)
+	}
+	return nil
+}
+
+// KillByName kills with SIGKILL every process with the name, which is matched
+// against both the full name and its base name. Processes that cannot be
+// killed are reported as ErrWarnings.
+func (d Device) KillByName(name string) error {
+	processes, err := d.Processes()
+	if err != nil {
+		return err
+	}
+
+	var warnings []string
+	found := false
+	for _, p := range processes {
+		if p.Name != name && path.Base(p.Name) != name {
+			continue
+		}
+		found = true
+		err = d.KillPID(p.PID, syscall.SIGKILL)
+		if err != nil {
+			warnings = append(warnings, err.Error())
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("adb kill %s: %w", name, ErrProcessNotFound)
+	}
+	if len(warnings) > 0 {
+		return ErrWarnings(warnings)
+	}
+	return nil
+}
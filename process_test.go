@@ -0,0 +1,70 @@
+package gadb
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func Test_parseProcesses(t *testing.T) {
+	tests := map[string]struct {
+		output string
+		want   []ProcessInfo
+	}{
+		"toybox": {
+			output: "  PID  PPID   RSS NAME\n" +
+				"    1     0 10296 init\n" +
+				"  612     1 78032 zygote64\n" +
+				" 4321   612 95340 com.example.app:remote\n",
+			want: []ProcessInfo{
+				{PID: 1, PPID: 0, RSS: 10296, Name: "init"},
+				{PID: 612, PPID: 1, RSS: 78032, Name: "zygote64"},
+				{PID: 4321, PPID: 612, RSS: 95340, Name: "com.example.app:remote"},
+			},
+		},
+		"toolbox": {
+			output: "USER     PID   PPID  VSIZE  RSS     WCHAN    PC         NAME\r\n" +
+				"root      1     0     8904   788   ffffffff 00000000 S /init\r\n" +
+				"u0_a12    2345  178   512345 40123 ffffffff 00000000 S com.android.systemui\r\n",
+			want: []ProcessInfo{
+				{PID: 1, PPID: 0, RSS: 788, Name: "/init"},
+				{PID: 2345, PPID: 178, RSS: 40123, Name: "com.android.systemui"},
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		got, err := parseProcesses(tt.output)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: parseProcesses() = %+v, want %+v", name, got, tt.want)
+		}
+	}
+
+	if _, err := parseProcesses("bad pid '-A'\n"); err == nil {
+		t.Error("expected error without header")
+	}
+}
+
+func TestDevice_KillByName(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	err = devices[0].KillByName("gadb-no-such-process")
+	if !errors.Is(err, ErrProcessNotFound) {
+		t.Errorf("KillByName() error = %v, want %v", err, ErrProcessNotFound)
+	}
+}
@@ -0,0 +1,97 @@
+package gadb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Properties returns all system properties of the device
+func (d Device) Properties() (map[string]string, error) {
+	output, err := d.RunShellCommand("getprop")
+	if err != nil {
+		return nil, fmt.Errorf("adb getprop: %w", err)
+	}
+	return parseProperties(output), nil
+}
+
+// GetProp returns the value of a system property of the device. An unset
+// property returns an empty string.
+func (d Device) GetProp(key string) (string, error) {
+	output, err := d.RunShellCommand("getprop", key)
+	if err != nil {
+		return "", fmt.Errorf("adb getprop %s: %w", key, err)
+	}
+	return strings.TrimRight(output, "\r\n"), nil
+}
+
+// SDKVersion returns the API level of the device (ro.build.version.sdk)
+func (d Device) SDKVersion() (int, error) {
+	value, err := d.GetProp("ro.build.version.sdk")
+	if err != nil {
+		return 0, err
+	}
+	sdk, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("adb getprop ro.build.version.sdk: invalid value %q", value)
+	}
+	return sdk, nil
+}
+
+// ReleaseVersion returns the Android version of the device, such as "13"
+// (ro.build.version.release)
+func (d Device) ReleaseVersion() (string, error) {
+	return d.GetProp("ro.build.version.release")
+}
+
+// ABIList returns the ABIs supported by the device, preferred first
+// (ro.product.cpu.abilist)
+func (d Device) ABIList() ([]string, error) {
+	value, err := d.GetProp("ro.product.cpu.abilist")
+	if err != nil {
+		return nil, err
+	}
+	if value == "" {
+		// Devices older than Lollipop only report their main ABI
+		value, err = d.GetProp("ro.product.cpu.abi")
+		if err != nil || value == "" {
+			return nil, err
+		}
+	}
+	return strings.Split(value, ","), nil
+}
+
+// parseProperties parses the "[key]: [value]" lines of getprop. Values may
+// contain brackets, be empty, or span multiple lines.
+func parseProperties(output string) map[string]string {
+	props := map[string]string{}
+
+	var key string
+	var value strings.Builder
+	inValue := false
+	for _, l := range strings.Split(output, "\n") {
+		l = strings.TrimRight(l, "\r")
+
+		if !inValue {
+			sep := strings.Index(l, "]: [")
+			if !strings.HasPrefix(l, "[") || sep < 0 {
+				continue
+			}
+			key = l[1:sep]
+			l = l[sep+len("]: ["):]
+			value.Reset()
+		} else {
+			value.WriteString("\n")
+		}
+
+		if strings.HasSuffix(l, "]") {
+			value.WriteString(strings.TrimSuffix(l, "]"))
+			props[key] = value.String()
+			inValue = false
+			continue
+		}
+		value.WriteString(l)
+		inValue = true
+	}
+	return props
+}
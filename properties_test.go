@@ -0,0 +1,96 @@
+package gadb
+
+import (
+	"testing"
+)
+
+func Test_parseProperties(t *testing.T) {
+	output := "[ro.build.version.sdk]: [33]\r\n" +
+		"[ro.product.model]: [Pixel 7]\n" +
+		"[persist.sys.empty]: []\n" +
+		"[ro.weird]: [a [b] c]\n" +
+		"[ro.multi]: [line one\n" +
+		"line two]\n" +
+		"garbage\n" +
+		"[ro.product.cpu.abilist]: [arm64-v8a,armeabi-v7a,armeabi]\n"
+
+	want := map[string]string{
+		"ro.build.version.sdk":   "33",
+		"ro.product.model":       "Pixel 7",
+		"persist.sys.empty":      "",
+		"ro.weird":               "a [b] c",
+		"ro.multi":               "line one\nline two",
+		"ro.product.cpu.abilist": "arm64-v8a,armeabi-v7a,armeabi",
+	}
+
+	props := parseProperties(output)
+	if len(props) != len(want) {
+		t.Fatalf("got %d properties, want %d: %v", len(props), len(want), props)
+	}
+	for k, v := range want {
+		if props[k] != v {
+			t.Errorf("props[%q] = %q, want %q", k, props[k], v)
+		}
+	}
+}
+
+func TestDevice_Properties(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	props, err := devices[0].Properties()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sdk, err := devices[0].GetProp("ro.build.version.sdk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if props["ro.build.version.sdk"] != sdk {
+		t.Errorf("GetProp = %q, Properties = %q", sdk, props["ro.build.version.sdk"])
+	}
+}
+
+func TestDevice_SDKVersion(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	sdk, err := devices[0].SDKVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	release, err := devices[0].ReleaseVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	abis, err := devices[0].ABIList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sdk <= 0 || release == "" || len(abis) == 0 {
+		t.Errorf("unexpected versions: sdk %d, release %q, abis %q", sdk, release, abis)
+	}
+}
@@ -21,3 +21,17 @@ func (r *readerCtx) Read(p []byte) (n int, err error) {
 func NewReader(ctx context.Context, r io.Reader) io.Reader {
 	return &readerCtx{ctx: ctx, r: r}
 }
+
+// closeOnCancel closes c once ctx is done, unblocking any pending read on it.
+// The returned stop function must be called to release the watcher.
+func closeOnCancel(ctx context.Context, c io.Closer) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = c.Close()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
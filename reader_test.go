@@ -0,0 +1,64 @@
+package gadb
+
+import (
+	"context"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func Test_closeOnCancel(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stop := closeOnCancel(ctx, client)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := client.Read(make([]byte, 16))
+		errCh <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Error("Read() succeeded after cancel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Read() still blocked after cancel")
+	}
+}
+
+func Test_closeOnCancel_stop(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	// Never cancelled before the watcher is stopped
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	before := runtime.NumGoroutine()
+	stop := closeOnCancel(ctx, client)
+	stop()
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines after stop, want %d", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	go server.Read(make([]byte, 1))
+	if _, err := client.Write([]byte("x")); err != nil {
+		t.Errorf("connection closed after stop: %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package gadb
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// WithRetry returns a copy of the client whose request-response commands
+// are attempted up to attempts times when they fail on a transient
+// connection error, waiting backoff before the first retry and twice as
+// long before each next one. Queries such as Version or List are retried on
+// any transient error, commands with side effects such as Connect or Pair
+// only when the connection failed before they were sent. Errors reported by
+// the adb server are not retried, neither are streaming commands such as
+// shell, sync or TrackDevices, which are not safe to replay.
+func (c Client) WithRetry(attempts int, backoff time.Duration) Client {
+	c.retryAttempts = attempts
+	c.retryBackoff = backoff
+	return c
+}
+
+// isTransient reports whether err is a connection error worth retrying
+func isTransient(err error) bool {
+	var netErr net.Error
+	return isDisconnect(err) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// idempotentServices are the host services that only query state and can be
+// sent again when their response is lost
+var idempotentServices = map[string]bool{
+	"version":      true,
+	"features":     true,
+	"devices":      true,
+	"devices-l":    true,
+	"list-forward": true,
+	"get-state":    true,
+	"get-devpath":  true,
+	"get-serialno": true,
+}
+
+// isIdempotent reports whether the host command only queries state, either
+// of the server (host:version) or of a device (host-serial:xxx:get-state)
+func isIdempotent(command string) bool {
+	if service := strings.TrimPrefix(command, "host:"); service != command {
+		return idempotentServices[service]
+	}
+	i := strings.LastIndexByte(command, ':')
+	return i >= 0 && idempotentServices[command[i+1:]]
+}
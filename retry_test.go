@@ -0,0 +1,111 @@
+package gadb
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestClient_WithRetry(t *testing.T) {
+	serve := fakeServer(t, func(request string) string {
+		if request != "host:version" {
+			return "FAIL0007unknown"
+		}
+		return "OKAY00040029"
+	})
+	refused, dials := 0, 0
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dials++
+		if refused > 0 {
+			refused--
+			return nil, &net.OpError{Op: "dial", Net: network, Err: syscall.ECONNREFUSED}
+		}
+		return serve(ctx, network, addr)
+	}
+
+	c, err := NewClientWithHost("fake", WithDialer(dial), WithPoolSize(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refused = 2
+	if _, err := c.Version(); !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Errorf("Version() without retry error = %v, want %v", err, syscall.ECONNREFUSED)
+	}
+
+	c = c.WithRetry(3, time.Millisecond)
+	refused = 2
+	if v, err := c.Version(); err != nil || v != 41 {
+		t.Errorf("Version() = %d, %v, want 41", v, err)
+	}
+
+	dials = 0
+	var adbErr *AdbError
+	if _, err := c.Features(); !errors.As(err, &adbErr) {
+		t.Errorf("Features() error = %v, want AdbError", err)
+	}
+	if dials != 1 {
+		t.Errorf("FAIL response dialed %d times, want 1", dials)
+	}
+}
+
+func TestClient_WithRetry_sent(t *testing.T) {
+	drop, requests := 0, map[string]int{}
+	c, err := NewClientWithHost("fake", WithPoolSize(0), WithDialer(fakeServer(t, func(request string) string {
+		requests[request]++
+		if drop > 0 {
+			// Close the connection after the request was received
+			drop--
+			return ""
+		}
+		switch {
+		case request == "host:version":
+			return "OKAY00040029"
+		case strings.HasPrefix(request, "host:connect:"):
+			return "OKAY0019connected to 1.2.3.4:5555"
+		}
+		return "FAIL0007unknown"
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = c.WithRetry(3, time.Millisecond)
+
+	drop = 1
+	if v, err := c.Version(); err != nil || v != 41 {
+		t.Errorf("Version() = %d, %v, want 41", v, err)
+	}
+
+	drop = 1
+	if err := c.ConnectHostAndPort("1.2.3.4", 5555); err == nil {
+		t.Error("ConnectHostAndPort() succeeded after the connection was lost")
+	}
+	if n := requests["host:connect:1.2.3.4:5555"]; n != 1 {
+		t.Errorf("host:connect sent %d times, want 1", n)
+	}
+}
+
+func Test_isIdempotent(t *testing.T) {
+	tests := []struct {
+		command string
+		want    bool
+	}{
+		{"host:version", true},
+		{"host:devices-l", true},
+		{"host-serial:192.168.1.2:5555:get-state", true},
+		{"host-transport-id:3:features", true},
+		{"host:connect:1.2.3.4:5555", false},
+		{"host:pair:123456:1.2.3.4:37000", false},
+		{"host:disconnect:", false},
+		{"host-serial:emulator-5554:reconnect", false},
+	}
+	for _, tt := range tests {
+		if got := isIdempotent(tt.command); got != tt.want {
+			t.Errorf("isIdempotent(%q) = %v, want %v", tt.command, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package gadb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrRootUnavailable is returned by RunShellCommandAsRoot when neither the
+// shell nor su run as root
+var ErrRootUnavailable = errors.New("root not available")
+
+// suTimeout bounds the probes of su, which may wait for the user to grant
+// root in a superuser app
+const suTimeout = 10 * time.Second
+
+// suFlavor is the way su takes the command to run as root
+type suFlavor int
+
+const (
+	suNone suFlavor = iota
+	// suShell means the shell already runs as root, after adb root
+	suShell
+	// suUID is the su of AOSP userdebug builds: su 0 <cmd> [args...]
+	suUID
+	// suDashC is the su of Magisk and SuperSU: su -c '<cmd line>'
+	suDashC
+)
+
+// IsRooted reports whether the device gives root without any change: the
+// shell runs as root, su grants it, or the build is debuggable so that
+// Root succeeds. ro.build.tags is not considered, test-keys builds are not
+// rooted by themselves.
+func (d Device) IsRooted() (bool, error) {
+	flavor, err := d.suFlavor()
+	if err != nil {
+		return false, err
+	}
+	if flavor != suNone {
+		return true, nil
+	}
+
+	debuggable, err := d.GetProp("ro.debuggable")
+	if err != nil {
+		return false, err
+	}
+	return debuggable == "1", nil
+}
+
+// RunShellCommandAsRoot runs a shell command on the device as root, through
+// the su of the device, whichever flavor it is, or directly when the shell
+// already runs as root. args are quoted as with RunShellCommand. su is
+// probed on each call, which costs up to three more shell commands.
+func (d Device) RunShellCommandAsRoot(cmd string, args ...string) (string, error) {
+	cmd = shellCommand(cmd, args)
+	if strings.TrimSpace(cmd) == "" {
+		return "", errors.New("adb shell: command cannot be empty")
+	}
+
+	flavor, err := d.suFlavor()
+	if err != nil {
+		return "", err
+	}
+	if flavor == suNone {
+		return "", fmt.Errorf("adb su: %w", ErrRootUnavailable)
+	}
+	return d.RunShellCommand(suCommand(flavor, cmd))
+}
+
+// suCommand wraps the command line to run as root with the flavor of su. The
+// su of AOSP runs its arguments without a shell, so the command line goes
+// through sh -c for pipes and redirections to work the same.
+func suCommand(flavor suFlavor, cmd string) string {
+	switch flavor {
+	case suUID:
+		return "su 0 sh -c " + shellQuote(cmd)
+	case suDashC:
+		return "su -c " + shellQuote(cmd)
+	default:
+		return cmd
+	}
+}
+
+// suFlavor finds how commands run as root on the device by running id with
+// each flavor of su in turn
+func (d Device) suFlavor() (suFlavor, error) {
+	probes := []struct {
+		flavor suFlavor
+		cmd    string
+	}{
+		{suShell, "id"},
+		{suUID, "su 0 id"},
+		{suDashC, "su -c id"},
+	}
+
+	for _, p := range probes {
+		ctx, cancel := context.WithTimeout(context.Background(), suTimeout)
+		output, err := d.RunShellCommandContext(ctx, p.cmd)
+		timedOut := ctx.Err() != nil
+		cancel()
+		if err != nil && !timedOut {
+			return suNone, err
+		}
+		if strings.Contains(output, "uid=0(") {
+			return p.flavor, nil
+		}
+	}
+	return suNone, nil
+}
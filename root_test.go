@@ -0,0 +1,41 @@
+package gadb
+
+import (
+	"testing"
+)
+
+func TestDevice_IsRooted(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	rooted, err := devices[0].IsRooted()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(rooted)
+}
+
+func Test_suCommand(t *testing.T) {
+	cmd := shellCommand("cat", []string{"/data/system/it's.xml"}) + " | head -n 1"
+
+	tests := map[suFlavor]string{
+		suShell: `cat '/data/system/it'\''s.xml' | head -n 1`,
+		suUID:   `su 0 sh -c 'cat '\''/data/system/it'\''\'\'''\''s.xml'\'' | head -n 1'`,
+		suDashC: `su -c 'cat '\''/data/system/it'\''\'\'''\''s.xml'\'' | head -n 1'`,
+	}
+	for flavor, want := range tests {
+		if got := suCommand(flavor, cmd); got != want {
+			t.Errorf("suCommand(%d) = %s, want %s", flavor, got, want)
+		}
+	}
+}
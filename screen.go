@@ -0,0 +1,150 @@
+package gadb
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Key codes of android.view.KeyEvent used by the screen helpers
+const (
+	KeycodeEnter  = 66
+	KeycodeSleep  = 223
+	KeycodeWakeUp = 224
+)
+
+// unlockDelay leaves the lock screen time to animate between the steps of
+// Unlock
+const unlockDelay = 500 * time.Millisecond
+
+// ScreenSize returns the size of the screen in pixels, as used by the input
+// commands: the override size set by wm size if any, the physical size
+// otherwise
+func (d Device) ScreenSize() (width, height int, err error) {
+	output, err := d.RunShellCommand("wm", "size")
+	if err != nil {
+		return 0, 0, fmt.Errorf("adb wm size: %w", err)
+	}
+
+	value, ok := parseWmValue(output)
+	if !ok {
+		return 0, 0, fmt.Errorf("adb wm size: unexpected output %q", output)
+	}
+	wh := strings.SplitN(value, "x", 2)
+	if len(wh) == 2 {
+		width, err = strconv.Atoi(wh[0])
+		if err == nil {
+			height, err = strconv.Atoi(wh[1])
+		}
+	}
+	if len(wh) != 2 || err != nil {
+		return 0, 0, fmt.Errorf("adb wm size: invalid size %q", value)
+	}
+	return width, height, nil
+}
+
+// ScreenDensity returns the density of the screen in dpi: the override
+// density set by wm density if any, the physical density otherwise
+func (d Device) ScreenDensity() (int, error) {
+	output, err := d.RunShellCommand("wm", "density")
+	if err != nil {
+		return 0, fmt.Errorf("adb wm density: %w", err)
+	}
+
+	value, ok := parseWmValue(output)
+	if !ok {
+		return 0, fmt.Errorf("adb wm density: unexpected output %q", output)
+	}
+	density, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("adb wm density: invalid density %q", value)
+	}
+	return density, nil
+}
+
+// parseWmValue returns the value of the "Override ...:" line of the output
+// of wm size or wm density, or of the "Physical ...:" line if there is none
+func parseWmValue(output string) (string, bool) {
+	var physical, override string
+	for _, l := range strings.Split(output, "\n") {
+		kv := strings.SplitN(l, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		switch {
+		case strings.HasPrefix(key, "Physical "):
+			physical = value
+		case strings.HasPrefix(key, "Override "):
+			override = value
+		}
+	}
+
+	if override != "" {
+		return override, true
+	}
+	return physical, physical != ""
+}
+
+// WakeUp turns the screen on, it does nothing if the screen is already on
+func (d Device) WakeUp() error {
+	return d.InputKeyevent(KeycodeWakeUp)
+}
+
+// Sleep turns the screen off, it does nothing if the screen is already off
+func (d Device) Sleep() error {
+	return d.InputKeyevent(KeycodeSleep)
+}
+
+// Unlock wakes the device up and swipes the lock screen up, then types the
+// PIN followed by enter if pin is not empty. The screen must be locked, the
+// swipe would otherwise reach the app underneath.
+func (d Device) Unlock(pin string) error {
+	err := d.WakeUp()
+	if err != nil {
+		return err
+	}
+	width, height, err := d.ScreenSize()
+	if err != nil {
+		return err
+	}
+
+	time.Sleep(unlockDelay)
+	err = d.InputSwipe(width/2, height*4/5, width/2, height/5, 300)
+	if err != nil || pin == "" {
+		return err
+	}
+
+	time.Sleep(unlockDelay)
+	err = d.InputText(pin)
+	if err != nil {
+		return err
+	}
+	return d.InputKeyevent(KeycodeEnter)
+}
+
+// IsScreenOn reports whether the screen is on, as reported by dumpsys power
+func (d Device) IsScreenOn() (bool, error) {
+	output, err := d.RunShellCommand("dumpsys", "power")
+	if err != nil {
+		return false, fmt.Errorf("adb dumpsys power: %w", err)
+	}
+	return parseScreenOn(output)
+}
+
+// parseScreenOn reads the display state of dumpsys power: "Display Power:
+// state=ON" since Android 5, "mScreenOn=true" before
+func parseScreenOn(output string) (bool, error) {
+	for _, l := range strings.Split(output, "\n") {
+		l = strings.TrimSpace(l)
+		if v := strings.TrimPrefix(l, "Display Power: state="); v != l {
+			return v == "ON", nil
+		}
+		if v := strings.TrimPrefix(l, "mScreenOn="); v != l {
+			return v == "true", nil
+		}
+	}
+	return false, errors.New("adb dumpsys power: no display state")
+}
@@ -0,0 +1,76 @@
+package gadb
+
+import (
+	"testing"
+)
+
+func Test_parseWmValue(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+		ok     bool
+	}{
+		{output: "Physical size: 1080x2400\n", want: "1080x2400", ok: true},
+		{output: "Physical size: 1080x2400\r\nOverride size: 720x1600\r\n", want: "720x1600", ok: true},
+		{output: "Physical density: 420\nOverride density: 320\n", want: "320", ok: true},
+		{output: "Physical density: 420\n", want: "420", ok: true},
+		{output: "/system/bin/sh: wm: not found\n"},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseWmValue(tt.output)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseWmValue(%q) = %q, %v, want %q, %v", tt.output, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func Test_parseScreenOn(t *testing.T) {
+	tests := []struct {
+		output string
+		want   bool
+	}{
+		{output: "POWER MANAGER (dumpsys power)\n\nDisplay Power: state=ON\r\n", want: true},
+		{output: "Display Power: state=OFF\n"},
+		{output: "Display Power: state=DOZE\n"},
+		{output: "  mScreenOn=true\n", want: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseScreenOn(tt.output)
+		if err != nil || got != tt.want {
+			t.Errorf("parseScreenOn(%q) = %v, %v, want %v", tt.output, got, err, tt.want)
+		}
+	}
+
+	if _, err := parseScreenOn("Can't find service: power\n"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestDevice_WakeUp(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	err = devices[0].WakeUp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	on, err := devices[0].IsScreenOn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !on {
+		t.Error("screen off after WakeUp")
+	}
+}
@@ -0,0 +1,46 @@
+package gadb
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// ScreenshotRaw returns the PNG encoded screenshot of the device.
+// Exec is used rather than the shell since some devices translate "\n"
+// into "\r\n" over shell:, corrupting the binary output.
+func (d Device) ScreenshotRaw() ([]byte, error) {
+	raw, err := d.Exec("screencap", "-p")
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(raw, pngSignature) {
+		return nil, fmt.Errorf("adb screencap: invalid png: %q", truncate(raw, 64))
+	}
+	return raw, nil
+}
+
+// Screenshot returns the decoded screenshot of the device
+func (d Device) Screenshot() (image.Image, error) {
+	raw, err := d.ScreenshotRaw()
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := png.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("adb screencap: failed to decode png: %w", err)
+	}
+	return img, nil
+}
+
+func truncate(b []byte, n int) []byte {
+	if len(b) > n {
+		return b[:n]
+	}
+	return b
+}
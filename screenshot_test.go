@@ -0,0 +1,27 @@
+package gadb
+
+import (
+	"testing"
+)
+
+func TestDevice_Screenshot(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	img, err := devices[0].Screenshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(devices[0].serial, img.Bounds())
+}
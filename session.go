@@ -162,6 +162,10 @@ func (s *Session) Start(cmd string) error {
 			buffer := make([]byte, 1024)
 			for !s.abort {
 				n, err := s.Stdin.Read(buffer)
+				// Readers may return the last data along with EOF
+				if n > 0 {
+					shellTp.Send(shellStdin, buffer[0:n])
+				}
 				if err == io.EOF {
 					if err := shellTp.Send(shellCloseStdin, []byte{}); err != nil {
 						s.errorChan <- fmt.Errorf("failed to close stdin: %w", err)
@@ -172,7 +176,6 @@ func (s *Session) Start(cmd string) error {
 					s.errorChan <- fmt.Errorf("failed to copy stdin: %w", err)
 					return
 				}
-				shellTp.Send(shellStdin, buffer[0:n])
 			}
 		}()
 	} else {
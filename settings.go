@@ -0,0 +1,60 @@
+package gadb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// List of settings namespaces
+const (
+	SettingsSystem = "system"
+	SettingsSecure = "secure"
+	SettingsGlobal = "global"
+)
+
+// ErrSettingNotSet is returned when a setting has no value
+var ErrSettingNotSet = errors.New("setting not set")
+
+// GetSetting returns the value of a setting in the system, secure or global namespace
+func (d Device) GetSetting(namespace, key string) (string, error) {
+	if err := checkSettingsNamespace(namespace); err != nil {
+		return "", err
+	}
+
+	output, err := d.RunShellCommand("settings", "get", namespace, key)
+	if err != nil {
+		return "", fmt.Errorf("adb settings get: %w", err)
+	}
+
+	value := strings.TrimRight(output, "\r\n")
+	if value == "null" {
+		return "", fmt.Errorf("adb settings get %s %s: %w", namespace, key, ErrSettingNotSet)
+	}
+	return value, nil
+}
+
+// PutSetting sets the value of a setting in the system, secure or global namespace
+func (d Device) PutSetting(namespace, key, value string) error {
+	if err := checkSettingsNamespace(namespace); err != nil {
+		return err
+	}
+
+	output, err := d.RunShellCommand("settings", "put", namespace, key, value)
+	if err != nil {
+		return fmt.Errorf("adb settings put: %w", err)
+	}
+	if output = strings.TrimSpace(output); output != "" {
+		return fmt.Errorf("adb settings put: %s", output)
+	}
+	return nil
+}
+
+func checkSettingsNamespace(namespace string) error {
+	switch namespace {
+	case SettingsSystem, SettingsSecure, SettingsGlobal:
+		return nil
+	default:
+		return fmt.Errorf("adb settings: invalid namespace: %q", namespace)
+	}
+}
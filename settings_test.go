@@ -0,0 +1,39 @@
+package gadb
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDevice_Setting(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	scale, err := devices[0].GetSetting(SettingsGlobal, "window_animation_scale")
+	if errors.Is(err, ErrSettingNotSet) {
+		scale = "1.0"
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	err = devices[0].PutSetting(SettingsGlobal, "window_animation_scale", scale)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = devices[0].GetSetting("invalid", "window_animation_scale")
+	if err == nil {
+		t.Fatal("expected error for invalid namespace")
+	}
+}
@@ -0,0 +1,235 @@
+package gadb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ShellResult is the result of a shell command run with the shell v2 protocol
+type ShellResult struct {
+	Stdout string
+	Stderr string
+	// ExitCode is the exit status of the command, or -1 if the device does not
+	// support the shell v2 protocol, in which case Stdout holds the merged output
+	ExitCode int
+}
+
+// failure returns the error output of a command printing nothing on
+// success, or its exit status if it printed nothing. It is empty if the
+// command succeeded.
+func (r ShellResult) failure() string {
+	msg := strings.TrimSpace(r.Stderr)
+	switch r.ExitCode {
+	case 0:
+		return ""
+	case -1:
+		// Without shell v2 the output is merged and the exit code unknown
+		return strings.TrimSpace(r.Stdout)
+	default:
+		if msg == "" {
+			msg = fmt.Sprintf("exit status %d", r.ExitCode)
+		}
+		return msg
+	}
+}
+
+// RunShellV2 runs a shell command on the device with the shell v2 protocol,
+// which keeps stdout and stderr apart and reports the exit code. A non zero
+// exit code is not an error. Devices without the shell_v2 feature fall back
+// to the legacy shell: service.
+func (d Device) RunShellV2(ctx context.Context, cmd string, args ...string) (ShellResult, error) {
+	cmd = shellCommand(cmd, args)
+	if strings.TrimSpace(cmd) == "" {
+		return ShellResult{}, errors.New("adb shell: command cannot be empty")
+	}
+	return d.runShellV2(ctx, cmd, nil)
+}
+
+// RunShellCommandStdin runs a shell command on the device with stdin as its
+// standard input, e.g. a script for sqlite3, and returns its output, stderr
+// following stdout. stdin is sent without a PTY so it reaches the command
+// byte for byte. Devices without shell v2 need a connection supporting
+// half-closing, as the adb server connection does.
+func (d Device) RunShellCommandStdin(cmd string, stdin io.Reader, args ...string) (string, error) {
+	cmd = shellCommand(cmd, args)
+	if strings.TrimSpace(cmd) == "" {
+		return "", errors.New("adb shell: command cannot be empty")
+	}
+
+	result, err := d.runShellV2(context.Background(), cmd, stdin)
+	if err != nil {
+		return "", err
+	}
+	return result.Stdout + result.Stderr, nil
+}
+
+// runShellV2 runs the command line as RunShellV2 does, feeding stdin to it
+// if not nil. Without shell v2, a command with stdin runs with exec: which
+// keeps stdin binary clean, and needs the end of stdin to be signaled
+// without closing the connection.
+func (d Device) runShellV2(ctx context.Context, cmd string, stdin io.Reader) (ShellResult, error) {
+	ok, err := d.HasFeature("shell_v2")
+	if err != nil {
+		return ShellResult{}, err
+	}
+	if !ok && stdin != nil {
+		output, err := d.execStdin(cmd, stdin)
+		return ShellResult{Stdout: output, ExitCode: -1}, err
+	}
+	if !ok {
+		output, err := d.RunShellCommandContext(ctx, cmd)
+		return ShellResult{Stdout: output, ExitCode: -1}, err
+	}
+
+	session, err := d.NewSession()
+	if err != nil {
+		return ShellResult{}, err
+	}
+	defer session.Close()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdin = stdin
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+
+	stop := closeOnCancel(ctx, session.transport)
+	defer stop()
+
+	result := ShellResult{}
+	err = session.Run(cmd)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ShellResult{Stdout: stdout.String(), Stderr: stderr.String()}, fmt.Errorf("adb shell: %w", ctxErr)
+	}
+
+	var exitErr *ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitStatus()
+	case err != nil:
+		return ShellResult{}, err
+	}
+
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	return result, nil
+}
+
+// execStdin runs the command line with exec:, copies stdin to it, then
+// half-closes the connection so that the command reads the end of its input
+func (d Device) execStdin(cmd string, stdin io.Reader) (string, error) {
+	tp, err := d.createDeviceTransport()
+	if err != nil {
+		return "", err
+	}
+	defer tp.Close()
+
+	err = tp.Send("exec:" + cmd)
+	if err != nil {
+		return "", err
+	}
+	err = tp.VerifyResponse()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = io.Copy(tp.sock, stdin)
+	if err != nil {
+		return "", fmt.Errorf("adb exec: failed to copy stdin: %w", err)
+	}
+
+	// Closing would be the only way left to end stdin, losing the output and
+	// the completion of the command
+	conn := timeoutConn{Conn: tp.sock, readTimeout: tp.readTimeout}
+	err = conn.CloseWrite()
+	if errors.Is(err, errHalfCloseUnsupported) {
+		return "", fmt.Errorf("adb exec: stdin %w", err)
+	}
+	if err != nil {
+		return "", fmt.Errorf("adb exec: failed to close stdin: %w", err)
+	}
+
+	output, err := io.ReadAll(conn)
+	if err != nil {
+		return "", fmt.Errorf("failed to read cmd response: %w", err)
+	}
+	return string(output), nil
+}
+
+// exitSentinel is echoed after the command by RunShellCommandExit, followed
+// by its exit status
+const exitSentinel = "__EXIT__"
+
+// RunShellCommandExit runs a shell command on the device and returns its
+// output along with its exit code. Unlike RunShellV2, it works on devices
+// without shell v2 by echoing the exit status after the output, stdout and
+// stderr are merged.
+func (d Device) RunShellCommandExit(cmd string, args ...string) (output string, exitCode int, err error) {
+	cmd = shellCommand(cmd, args)
+	if strings.TrimSpace(cmd) == "" {
+		return "", 0, errors.New("adb shell: command cannot be empty")
+	}
+
+	raw, err := d.RunShellCommandRaw(cmd + `; echo -n "` + exitSentinel + `$?"`)
+	if err != nil {
+		return "", 0, err
+	}
+	return parseExitOutput(raw)
+}
+
+// parseExitOutput splits the output of RunShellCommandExit at the last
+// sentinel, the command output may contain it too
+func parseExitOutput(raw string) (string, int, error) {
+	i := strings.LastIndex(raw, exitSentinel)
+	if i < 0 {
+		return "", 0, fmt.Errorf("adb shell: exit status not found in %q", truncate([]byte(raw), 64))
+	}
+
+	// Legacy shells running on a pty may add a \r\n
+	code, err := strconv.Atoi(strings.TrimSpace(raw[i+len(exitSentinel):]))
+	if err != nil {
+		return "", 0, fmt.Errorf("adb shell: invalid exit status: %w", err)
+	}
+	return raw[:i], code, nil
+}
+
+// shellCommand builds the command line of cmd, quoting each of args
+func shellCommand(cmd string, args []string) string {
+	quoted := make([]string, 0, len(args)+1)
+	quoted = append(quoted, cmd)
+	for _, arg := range args {
+		quoted = append(quoted, shellQuote(arg))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// shellQuote quotes arg for the device shell, leaving it as is when it is safe
+func shellQuote(arg string) string {
+	if arg == "" {
+		return "''"
+	}
+
+	safe := true
+	for _, r := range arg {
+		if !isShellSafe(r) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+func isShellSafe(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	}
+	return strings.ContainsRune("@%+=:,./_-", r)
+}
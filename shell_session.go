@@ -0,0 +1,114 @@
+package gadb
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ShellSession runs multiple commands over a single shell connection, saving
+// a connection per command. It is not safe for concurrent use.
+type ShellSession struct {
+	sock        net.Conn
+	reader      *bufio.Reader
+	readTimeout time.Duration
+	marker      string
+}
+
+// Shell opens a persistent shell on the device
+func (d Device) Shell() (*ShellSession, error) {
+	tp, err := d.createDeviceTransport()
+	if err != nil {
+		return nil, err
+	}
+
+	err = tp.Send("shell:sh")
+	if err != nil {
+		tp.Close()
+		return nil, err
+	}
+
+	err = tp.VerifyResponse()
+	if err != nil {
+		tp.Close()
+		return nil, err
+	}
+
+	return &ShellSession{
+		sock:        tp.sock,
+		reader:      bufio.NewReader(tp.sock),
+		readTimeout: tp.readTimeout,
+		marker:      fmt.Sprintf("__GADB_%d__", time.Now().UnixNano()),
+	}, nil
+}
+
+// Run runs the command line in the shell and returns its output. The shell
+// state (working directory, variables, ...) is kept between commands.
+// A non zero exit status is reported as an *ExitError.
+func (s *ShellSession) Run(cmd string) (string, error) {
+	if s.sock == nil {
+		return "", errors.New("adb shell: session closed")
+	}
+	if strings.TrimSpace(cmd) == "" {
+		return "", errors.New("adb shell: command cannot be empty")
+	}
+
+	// The markers are split in two quoted strings so that, on devices echoing
+	// the input through a PTY, the echo never matches them
+	start, end := s.marker+"S", s.marker+"E "
+	line := fmt.Sprintf("echo '%s''S'; %s\necho '%s''E' $?\n", s.marker, cmd, s.marker)
+	err := _send(s.sock, []byte(line))
+	if err != nil {
+		return "", err
+	}
+
+	_ = s.sock.SetReadDeadline(readDeadline(s.readTimeout))
+
+	for {
+		l, err := s.reader.ReadString('\n')
+		if err != nil {
+			return "", fmt.Errorf("adb shell: %w", err)
+		}
+		if strings.TrimRight(l, "\r\n") == start {
+			break
+		}
+	}
+
+	var output strings.Builder
+	for {
+		l, err := s.reader.ReadString('\n')
+		if err != nil {
+			return output.String(), fmt.Errorf("adb shell: %w", err)
+		}
+
+		i := strings.Index(l, end)
+		if i < 0 {
+			output.WriteString(strings.ReplaceAll(l, "\r\n", "\n"))
+			continue
+		}
+		output.WriteString(l[:i])
+
+		exitStatus, err := strconv.Atoi(strings.TrimSpace(l[i+len(end):]))
+		if err != nil {
+			return output.String(), fmt.Errorf("adb shell: invalid exit status: %w", err)
+		}
+		if exitStatus != 0 {
+			return output.String(), &ExitError{Waitmsg: Waitmsg{exitStatus: exitStatus}}
+		}
+		return output.String(), nil
+	}
+}
+
+// Close closes the shell
+func (s *ShellSession) Close() error {
+	if s.sock == nil {
+		return nil
+	}
+	err := s.sock.Close()
+	s.sock = nil
+	return err
+}
@@ -0,0 +1,104 @@
+package gadb
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDevice_Shell(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	shell, err := devices[0].Shell()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shell.Close()
+
+	output, err := shell.Run("cd /sdcard && echo hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output != "hello\n" {
+		t.Fatalf("Run() = %q, want %q", output, "hello\n")
+	}
+
+	output, err = shell.Run("pwd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output != "/sdcard\n" {
+		t.Fatalf("Run() = %q, want %q", output, "/sdcard\n")
+	}
+
+	_, err = shell.Run("exit_with() { return $1; }; exit_with 3")
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitStatus() != 3 {
+		t.Fatalf("expected exit status 3, got %v", err)
+	}
+}
+
+func BenchmarkShellSession_Run(b *testing.B) {
+	c, err := NewClient()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		b.SkipNow()
+	}
+
+	shell, err := devices[0].Shell()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer shell.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := shell.Run("echo hello")
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDevice_RunShellCommand(b *testing.B) {
+	c, err := NewClient()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		b.SkipNow()
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := devices[0].RunShellCommand("echo", "hello")
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package gadb
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDevice_RunShellV2(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	result, err := devices[0].RunShellV2(context.Background(), "echo out; echo err >&2; exit 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ExitCode == -1 {
+		t.Skip("shell_v2 not supported")
+	}
+
+	if result.Stdout != "out\n" || result.Stderr != "err\n" || result.ExitCode != 3 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
+
+func Test_shellQuote(t *testing.T) {
+	tests := map[string]string{
+		"":                "''",
+		"simple":          "simple",
+		"/sdcard/a.txt":   "/sdcard/a.txt",
+		"with space":      "'with space'",
+		"it's":            `'it'\''s'`,
+		`"double"`:        `'"double"'`,
+		"a; reboot":       "'a; reboot'",
+		"$(reboot)":       "'$(reboot)'",
+		"`reboot`":        "'`reboot`'",
+		"key=value,other": "key=value,other",
+	}
+
+	for arg, want := range tests {
+		if got := shellQuote(arg); got != want {
+			t.Errorf("shellQuote(%q) = %s, want %s", arg, got, want)
+		}
+	}
+}
+
+func Test_shellCommand(t *testing.T) {
+	got := shellCommand("ls -l", []string{"/sdcard/My Files", "a'b"})
+	want := `ls -l '/sdcard/My Files' 'a'\''b'`
+	if got != want {
+		t.Errorf("shellCommand() = %s, want %s", got, want)
+	}
+}
+
+func Test_parseExitOutput(t *testing.T) {
+	tests := []struct {
+		raw    string
+		output string
+		code   int
+	}{
+		{"hello\n__EXIT__0", "hello\n", 0},
+		{"__EXIT__127", "", 127},
+		{"no newline__EXIT__1", "no newline", 1},
+		{"echo __EXIT__5\n__EXIT__2\r\n", "echo __EXIT__5\n", 2},
+	}
+
+	for _, tt := range tests {
+		output, code, err := parseExitOutput(tt.raw)
+		if err != nil {
+			t.Errorf("parseExitOutput(%q) error: %v", tt.raw, err)
+			continue
+		}
+		if output != tt.output || code != tt.code {
+			t.Errorf("parseExitOutput(%q) = %q, %d, want %q, %d", tt.raw, output, code, tt.output, tt.code)
+		}
+	}
+
+	for _, raw := range []string{"no sentinel", "__EXIT__x", "__EXIT__0 trailing"} {
+		if _, _, err := parseExitOutput(raw); err == nil {
+			t.Errorf("parseExitOutput(%q) expected error", raw)
+		}
+	}
+}
+
+func TestDevice_RunShellCommandExit(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	output, code, err := devices[0].RunShellCommandExit("ls", "/does/not/exist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code == 0 {
+		t.Errorf("exit code = 0, want non zero, output: %q", output)
+	}
+}
+
+func TestDevice_RunShellCommandStdin(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	output, err := devices[0].RunShellCommandStdin("wc", strings.NewReader("one\ntwo\r\nthree\n"), "-l")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(output) != "3" {
+		t.Errorf("RunShellCommandStdin() = %q, want 3", output)
+	}
+}
+
+func TestDevice_RunShellCommandStdin_noHalfClose(t *testing.T) {
+	// In-memory pipes cannot be half-closed, like custom dialers may not
+	dial := fakeServer(t, func(request string) string {
+		if strings.HasSuffix(request, ":features") {
+			return "OKAY0003cmd"
+		}
+		return "OKAY"
+	}, func(_ string, conn net.Conn) {
+		_, _ = io.Copy(ioutil.Discard, conn)
+	})
+	d := Device{adbClient: Client{readTimeout: defaultAdbReadTimeout, dial: dial}, serial: "fake"}
+
+	_, err := d.RunShellCommandStdin("wc", strings.NewReader("one\n"), "-l")
+	if !errors.Is(err, errHalfCloseUnsupported) {
+		t.Errorf("RunShellCommandStdin() error = %v, want %v", err, errHalfCloseUnsupported)
+	}
+}
+
+func TestDevice_RunShellCommandStdin_dataWithEOF(t *testing.T) {
+	// Echoes the stdin of a shell v2 command as its stdout, like cat
+	dial := fakeServer(t, func(request string) string {
+		if strings.HasSuffix(request, ":features") {
+			return "OKAY000fshell_v2,cmd,ls"
+		}
+		return "OKAY"
+	}, func(request string, conn net.Conn) {
+		if !strings.HasPrefix(request, "shell,v2,") {
+			return
+		}
+		tp := newShellTransport(conn, 0)
+		var stdin []byte
+		for {
+			id, data, err := tp.Read()
+			if err != nil {
+				return
+			}
+			if id == shellCloseStdin {
+				break
+			}
+			stdin = append(stdin, data...)
+		}
+		_ = tp.Send(shellStdout, stdin)
+		_ = tp.Send(shellExit, []byte{0})
+	})
+	d := Device{adbClient: Client{readTimeout: defaultAdbReadTimeout, dial: dial}, serial: "fake"}
+
+	output, err := d.RunShellCommandStdin("cat", iotest.DataErrReader(strings.NewReader("one\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output != "one\n" {
+		t.Errorf("RunShellCommandStdin() = %q, want %q", output, "one\n")
+	}
+}
+
+func TestShellResult_failure(t *testing.T) {
+	tests := []struct {
+		result ShellResult
+		want   string
+	}{
+		{result: ShellResult{Stdout: "ignored\n"}, want: ""},
+		{result: ShellResult{Stderr: "rm: x: No such file or directory\n", ExitCode: 1}, want: "rm: x: No such file or directory"},
+		{result: ShellResult{ExitCode: 2}, want: "exit status 2"},
+		{result: ShellResult{Stdout: "kill: 1: Operation not permitted\n", ExitCode: -1}, want: "kill: 1: Operation not permitted"},
+		{result: ShellResult{ExitCode: -1}, want: ""},
+	}
+	for _, tt := range tests {
+		if got := tt.result.failure(); got != tt.want {
+			t.Errorf("%+v.failure() = %q, want %q", tt.result, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package gadb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const (
+	sideloadBlockSize = 64 * 1024
+)
+
+// Sideload sends an OTA package to a device rebooted in sideload mode (see
+// RebootSideload). The device requests the blocks of the package in any
+// order, hence the io.ReaderAt.
+func (d Device) Sideload(ctx context.Context, zip io.ReaderAt, size int64) error {
+	if size <= 0 {
+		return errors.New("adb sideload: size must be positive")
+	}
+
+	tp, err := d.createDeviceTransport()
+	if err != nil {
+		return err
+	}
+	defer tp.Close()
+
+	stop := closeOnCancel(ctx, tp)
+	defer stop()
+
+	err = tp.Send(fmt.Sprintf("sideload-host:%d:%d", size, sideloadBlockSize))
+	if err != nil {
+		return err
+	}
+
+	err = tp.VerifyResponse()
+	if err != nil {
+		return err
+	}
+
+	// Verifying the package may take a while between requests
+	tp.readTimeout = 0
+
+	block := make([]byte, sideloadBlockSize)
+	for {
+		request, err := tp.ReadStringN(8)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return fmt.Errorf("adb sideload: %w", ctxErr)
+			}
+			return fmt.Errorf("adb sideload: failed to read request: %w", err)
+		}
+
+		switch request {
+		case "DONEDONE":
+			return nil
+		case "FAILFAIL":
+			return errors.New("adb sideload: installation failed")
+		}
+
+		n, err := strconv.ParseInt(request, 10, 64)
+		if err != nil {
+			return fmt.Errorf("adb sideload: invalid block request: %q", request)
+		}
+
+		offset := n * sideloadBlockSize
+		if offset >= size {
+			return fmt.Errorf("adb sideload: block %d past the end of the package", n)
+		}
+
+		length := int64(sideloadBlockSize)
+		if offset+length > size {
+			length = size - offset
+		}
+
+		_, err = zip.ReadAt(block[:length], offset)
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("adb sideload: failed to read block %d: %w", n, err)
+		}
+
+		err = _send(tp.sock, block[:length])
+		if err != nil {
+			return fmt.Errorf("adb sideload: failed to send block %d: %w", n, err)
+		}
+	}
+}
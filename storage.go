@@ -0,0 +1,42 @@
+package gadb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DiskUsage returns the size, the used and the available space in bytes of
+// the filesystem holding the path
+func (d Device) DiskUsage(path string) (total, used, available int64, err error) {
+	output, err := d.RunShellCommand("df", path)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("adb df: %w", err)
+	}
+	return parseDf(output)
+}
+
+// parseDf parses the output of df with 1K blocks for a single filesystem.
+// Busybox wraps the line after a long filesystem name, so the fields are read
+// across lines.
+func parseDf(output string) (total, used, available int64, err error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	header := strings.Fields(lines[0])
+	if len(lines) < 2 || len(header) < 4 || !strings.EqualFold(header[1], "1K-blocks") {
+		return 0, 0, 0, fmt.Errorf("adb df: unexpected output %q", truncate([]byte(output), 64))
+	}
+
+	fields := strings.Fields(strings.Join(lines[1:], " "))
+	if len(fields) < 4 {
+		return 0, 0, 0, fmt.Errorf("adb df: unexpected output %q", truncate([]byte(output), 64))
+	}
+
+	var blocks [3]int64
+	for i := range blocks {
+		blocks[i], err = strconv.ParseInt(fields[i+1], 10, 64)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("adb df: invalid block count %q", fields[i+1])
+		}
+	}
+	return blocks[0] * 1024, blocks[1] * 1024, blocks[2] * 1024, nil
+}
@@ -0,0 +1,53 @@
+package gadb
+
+import "testing"
+
+func Test_parseDf(t *testing.T) {
+	tests := map[string]string{
+		"toybox": "Filesystem       1K-blocks    Used Available Use% Mounted on\n" +
+			"/dev/block/dm-5  115712176 3571020 112010084   4% /data\n",
+		"wrapped": "Filesystem           1k-blocks      Used Available Use% Mounted on\r\n" +
+			"/dev/block/platform/msm_sdcc.1/by-name/userdata\r\n" +
+			"                     115712176   3571020 112010084   4% /data\r\n",
+	}
+
+	for name, output := range tests {
+		total, used, available, err := parseDf(output)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if total != 115712176*1024 || used != 3571020*1024 || available != 112010084*1024 {
+			t.Errorf("%s: parseDf() = %d, %d, %d", name, total, used, available)
+		}
+	}
+
+	_, _, _, err := parseDf("Filesystem  Size  Used  Free  Blksize\n/data  5.8G  2.1G  3.6G  4096\n")
+	if err == nil {
+		t.Error("expected error for toolbox output")
+	}
+}
+
+func TestDevice_DiskUsage(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	total, used, available, err := devices[0].DiskUsage("/data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total <= 0 || used+available > total+total/10 {
+		t.Errorf("DiskUsage() = %d, %d, %d", total, used, available)
+	}
+	t.Log(total, used, available)
+}
@@ -8,22 +8,40 @@ import (
 	"io"
 	"net"
 	"os"
+	"path"
+	"sync"
+	"syscall"
 	"time"
 )
 
 const (
 	syncMaxChunkSize = 64 * 1024
+
+	// syncHeaderSize is the size of the id and length preceding the data
+	syncHeaderSize = 8
 )
 
+// chunkPool holds the buffers of SendStream, room for a DATA header followed
+// by syncMaxChunkSize bytes
+var chunkPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, syncHeaderSize+syncMaxChunkSize)
+		return &b
+	},
+}
+
 type syncTransport struct {
 	sock        net.Conn
 	readTimeout time.Duration
+	// chunkSize is the size of the DATA chunks sent, at most syncMaxChunkSize
+	chunkSize int
 }
 
 func newSyncTransport(sock net.Conn, readTimeout time.Duration) syncTransport {
 	return syncTransport{
 		sock:        sock,
 		readTimeout: readTimeout,
+		chunkSize:   syncMaxChunkSize,
 	}
 }
 
@@ -46,21 +64,37 @@ func (sync syncTransport) Send(command, data string) error {
 	return nil
 }
 
-func (sync syncTransport) SendStream(reader io.Reader) error {
+// SendStream sends the reader in chunks, calling onProgress (if not nil)
+// with the cumulative number of bytes sent after each chunk. Data returned
+// along with an error by the reader is sent before the error is handled.
+func (sync syncTransport) SendStream(reader io.Reader, onProgress func(sent int64)) (int64, error) {
+	// A single buffer holds the header and the data of every chunk, sparing
+	// an allocation and a copy per chunk
+	buf := chunkPool.Get().(*[]byte)
+	defer chunkPool.Put(buf)
+	chunk := (*buf)[:syncHeaderSize+sync.chunkSize]
+	copy(chunk, "DATA")
+
+	var sent int64
 	for {
-		b := make([]byte, syncMaxChunkSize)
+		n, err := reader.Read(chunk[syncHeaderSize:])
+		if n > 0 {
+			binary.LittleEndian.PutUint32(chunk[4:syncHeaderSize], uint32(n))
+			if sendErr := _send(sync.sock, chunk[:syncHeaderSize+n]); sendErr != nil {
+				return sent, sendErr
+			}
+
+			sent += int64(n)
+			if onProgress != nil {
+				onProgress(sent)
+			}
+		}
 
-		n, err := reader.Read(b)
 		if err == io.EOF {
-			return nil
+			return sent, nil
 		}
 		if err != nil {
-			return err
-		}
-
-		err = sync.sendChunk(b[:n])
-		if err != nil {
-			return err
+			return sent, err
 		}
 	}
 }
@@ -79,21 +113,6 @@ func (sync syncTransport) SendStatus(statusCode string, n uint32) error {
 	return nil
 }
 
-func (sync syncTransport) sendChunk(buffer []byte) error {
-	msg := bytes.NewBufferString("DATA")
-	err := binary.Write(msg, binary.LittleEndian, int32(len(buffer)))
-	if err != nil {
-		return fmt.Errorf("sync transport write: %w", err)
-	}
-
-	msg.Write(buffer)
-	err = _send(sync.sock, msg.Bytes())
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
 func (sync syncTransport) VerifyStatus() error {
 	status, err := sync.ReadStringN(4)
 	if err != nil {
@@ -121,7 +140,10 @@ func (sync syncTransport) VerifyStatus() error {
 	return nil
 }
 
-func (sync syncTransport) WriteStream(dest io.Writer) error {
+// WriteStream writes the received chunks to dest, calling onProgress (if not
+// nil) with the cumulative number of bytes received after each chunk.
+func (sync syncTransport) WriteStream(dest io.Writer, onProgress func(received int64)) error {
+	var received int64
 	for {
 		chunk, err := sync.readChunk()
 		if err == io.EOF {
@@ -135,6 +157,11 @@ func (sync syncTransport) WriteStream(dest io.Writer) error {
 		if err != nil {
 			return fmt.Errorf("sync write stream: %w", err)
 		}
+
+		received += int64(len(chunk))
+		if onProgress != nil {
+			onProgress(received)
+		}
 	}
 }
 
@@ -188,10 +215,11 @@ func (sync syncTransport) ReadDirectoryEntry() (os.FileInfo, bool, error) {
 		return fileInfo{}, false, fmt.Errorf("sync transport read (mode): %w", err)
 	}
 
-	entry.size, err = sync.ReadUint32()
+	size, err := sync.ReadUint32()
 	if err != nil {
 		return fileInfo{}, false, fmt.Errorf("sync transport read (size): %w", err)
 	}
+	entry.size = int64(size)
 
 	lastModUnix, err := sync.ReadUint32()
 	if err != nil {
@@ -213,6 +241,173 @@ func (sync syncTransport) ReadDirectoryEntry() (os.FileInfo, bool, error) {
 	return entry, true, nil
 }
 
+func (sync syncTransport) ReadStat() (fileInfo, error) {
+	status, err := sync.ReadStringN(4)
+	if err != nil {
+		return fileInfo{}, err
+	}
+	if status != "STAT" {
+		return fileInfo{}, fmt.Errorf("sync transport read (stat): unexpected status %q", status)
+	}
+
+	var entry fileInfo
+
+	err = binary.Read(sync.sock, binary.LittleEndian, &entry.mode)
+	if err != nil {
+		return fileInfo{}, fmt.Errorf("sync transport read (mode): %w", err)
+	}
+
+	size, err := sync.ReadUint32()
+	if err != nil {
+		return fileInfo{}, fmt.Errorf("sync transport read (size): %w", err)
+	}
+	entry.size = int64(size)
+
+	lastModUnix, err := sync.ReadUint32()
+	if err != nil {
+		return fileInfo{}, fmt.Errorf("sync transport read (time): %w", err)
+	}
+
+	entry.modTime = time.Unix(int64(lastModUnix), 0)
+
+	return entry, nil
+}
+
+// syncStatV2 is the stat structure of the STA2, LST2 and DNT2 responses
+type syncStatV2 struct {
+	Error uint32
+	Dev   uint64
+	Ino   uint64
+	Mode  uint32
+	Nlink uint32
+	UID   uint32
+	GID   uint32
+	Size  uint64
+	Atime int64
+	Mtime int64
+	Ctime int64
+}
+
+func (sync syncTransport) readStatV2() (fileInfo, uint32, error) {
+	raw, err := sync.ReadBytesN(binary.Size(syncStatV2{}))
+	if err != nil {
+		return fileInfo{}, 0, err
+	}
+
+	var st syncStatV2
+	err = binary.Read(bytes.NewReader(raw), binary.LittleEndian, &st)
+	if err != nil {
+		return fileInfo{}, 0, err
+	}
+
+	stat := &FileStat{
+		Dev:   st.Dev,
+		Ino:   st.Ino,
+		Mode:  st.Mode,
+		Nlink: st.Nlink,
+		UID:   st.UID,
+		GID:   st.GID,
+		Size:  int64(st.Size),
+		Atime: time.Unix(st.Atime, 0),
+		Mtime: time.Unix(st.Mtime, 0),
+		Ctime: time.Unix(st.Ctime, 0),
+	}
+	return fileInfo{
+		mode:    os.FileMode(st.Mode),
+		size:    stat.Size,
+		modTime: stat.Mtime,
+		stat:    stat,
+	}, st.Error, nil
+}
+
+// ReadStatV2 reads the response of a STA2 or LST2 command. The error is the
+// errno of the failed stat, 0 on success.
+func (sync syncTransport) ReadStatV2() (fileInfo, error) {
+	status, err := sync.ReadStringN(4)
+	if err != nil {
+		return fileInfo{}, err
+	}
+	if status != "STA2" && status != "LST2" {
+		return fileInfo{}, fmt.Errorf("sync transport read (stat): unexpected status %q", status)
+	}
+
+	entry, errno, err := sync.readStatV2()
+	if err != nil {
+		return fileInfo{}, fmt.Errorf("sync transport read (stat): %w", err)
+	}
+	if errno != 0 {
+		return fileInfo{}, syscall.Errno(errno)
+	}
+	return entry, nil
+}
+
+// ReadDirectoryEntryV2 reads an entry of the response of a LIS2 command
+func (sync syncTransport) ReadDirectoryEntryV2() (os.FileInfo, bool, error) {
+	status, err := sync.ReadStringN(4)
+	if err != nil {
+		return fileInfo{}, false, err
+	}
+	if status == "DONE" {
+		return fileInfo{}, false, nil
+	}
+	if status != "DNT2" {
+		return fileInfo{}, false, fmt.Errorf("sync transport read (dent): unexpected status %q", status)
+	}
+
+	entry, _, err := sync.readStatV2()
+	if err != nil {
+		return fileInfo{}, false, fmt.Errorf("sync transport read (dent): %w", err)
+	}
+
+	fLen, err := sync.ReadUint32()
+	if err != nil {
+		return fileInfo{}, false, fmt.Errorf("sync transport read (file name length): %w", err)
+	}
+
+	entry.name, err = sync.ReadStringN(int(fLen))
+	if err != nil {
+		return fileInfo{}, false, fmt.Errorf("sync transport read (file name): %w", err)
+	}
+
+	return entry, true, nil
+}
+
+// Stat stats the remote path, using STA2 when v2 is set and STAT otherwise.
+// The returned error wraps os.ErrNotExist if the path does not exist.
+func (sync syncTransport) Stat(remotePath string, v2 bool) (fileInfo, error) {
+	command := "STAT"
+	if v2 {
+		command = "STA2"
+	}
+
+	err := sync.Send(command, remotePath)
+	if err != nil {
+		return fileInfo{}, fmt.Errorf("failed to send stat command: %w", err)
+	}
+
+	var entry fileInfo
+	if v2 {
+		entry, err = sync.ReadStatV2()
+		var errno syscall.Errno
+		if errors.As(err, &errno) {
+			return fileInfo{}, &os.PathError{Op: "stat", Path: remotePath, Err: errno}
+		}
+	} else {
+		entry, err = sync.ReadStat()
+	}
+	if err != nil {
+		return fileInfo{}, fmt.Errorf("failed to read stat: %w", err)
+	}
+
+	// adbd reports a zero mode when the path cannot be stat'ed
+	if entry.mode == 0 {
+		return fileInfo{}, &os.PathError{Op: "stat", Path: remotePath, Err: os.ErrNotExist}
+	}
+
+	entry.name = path.Base(remotePath)
+	return entry, nil
+}
+
 func (sync syncTransport) ReadUint32() (uint32, error) {
 	var n uint32
 	err := binary.Read(sync.sock, binary.LittleEndian, &n)
@@ -229,7 +424,7 @@ func (sync syncTransport) ReadStringN(size int) (string, error) {
 }
 
 func (sync syncTransport) ReadBytesN(size int) ([]byte, error) {
-	_ = sync.sock.SetReadDeadline(time.Now().Add(sync.readTimeout))
+	_ = sync.sock.SetReadDeadline(readDeadline(sync.readTimeout))
 	return _readN(sync.sock, size)
 }
 
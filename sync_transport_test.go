@@ -0,0 +1,162 @@
+package gadb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSyncTransport_ReadStatV2(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	go func() {
+		defer server.Close()
+
+		msg := bytes.NewBufferString("STA2")
+		_ = binary.Write(msg, binary.LittleEndian, syncStatV2{
+			Mode:  0o100644,
+			Nlink: 1,
+			UID:   2000,
+			GID:   2000,
+			Size:  5 << 30,
+			Mtime: 1700000000,
+		})
+		msg.WriteString("STA2")
+		_ = binary.Write(msg, binary.LittleEndian, syncStatV2{Error: 2})
+		_, _ = server.Write(msg.Bytes())
+	}()
+
+	sync := newSyncTransport(client, time.Second)
+
+	entry, err := sync.ReadStatV2()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Size() != 5<<30 {
+		t.Errorf("Size() = %d, want %d", entry.Size(), int64(5<<30))
+	}
+	if !entry.isRegular() {
+		t.Errorf("isRegular() = false, want true")
+	}
+	if entry.ModTime().Unix() != 1700000000 {
+		t.Errorf("ModTime() = %v", entry.ModTime())
+	}
+	stat, ok := entry.Sys().(*FileStat)
+	if !ok || stat.UID != 2000 {
+		t.Errorf("Sys() = %v", entry.Sys())
+	}
+
+	_, err = sync.ReadStatV2()
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected os.ErrNotExist, got %v", err)
+	}
+}
+
+func BenchmarkSyncTransport_SendStream(b *testing.B) {
+	const size = 100 << 20
+
+	for i := 0; i < b.N; i++ {
+		client, server := net.Pipe()
+		go func() {
+			_, _ = io.Copy(ioutil.Discard, server)
+		}()
+
+		sync := newSyncTransport(client, time.Second)
+		sent, err := sync.SendStream(io.LimitReader(zeroReader{}, size), nil)
+		if err != nil || sent != size {
+			b.Fatalf("SendStream() = %d, %v", sent, err)
+		}
+		client.Close()
+	}
+	b.SetBytes(size)
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+// dataErrReader returns its data along with err in a single Read
+type dataErrReader struct {
+	data string
+	err  error
+}
+
+func (r *dataErrReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, r.err
+}
+
+// sendStream runs SendStream over a pipe, returning what was written on it
+func sendStream(reader io.Reader) (written []byte, sent int64, err error) {
+	client, server := net.Pipe()
+	received := make(chan []byte)
+	go func() {
+		b, _ := ioutil.ReadAll(server)
+		received <- b
+	}()
+
+	sync := newSyncTransport(client, time.Second)
+	sent, err = sync.SendStream(reader, nil)
+	client.Close()
+	return <-received, sent, err
+}
+
+func TestSyncTransport_SendStream_dataWithError(t *testing.T) {
+	errBoom := errors.New("boom")
+	written, sent, err := sendStream(&dataErrReader{data: "abc", err: errBoom})
+	if !errors.Is(err, errBoom) {
+		t.Errorf("SendStream() error = %v, want %v", err, errBoom)
+	}
+	if sent != 3 {
+		t.Errorf("SendStream() sent = %d, want 3", sent)
+	}
+	if want := "DATA\x03\x00\x00\x00abc"; string(written) != want {
+		t.Errorf("written %q, want %q", written, want)
+	}
+}
+
+func TestSyncTransport_SendStream_dataWithEOF(t *testing.T) {
+	written, sent, err := sendStream(&dataErrReader{data: "final", err: io.EOF})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent != 5 {
+		t.Errorf("SendStream() sent = %d, want 5", sent)
+	}
+	if want := "DATA\x05\x00\x00\x00final"; string(written) != want {
+		t.Errorf("written %q, want %q", written, want)
+	}
+}
+
+func TestSyncTransport_SendStream_chunks(t *testing.T) {
+	data := bytes.Repeat([]byte{'x'}, syncMaxChunkSize+10)
+	written, sent, err := sendStream(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent != int64(len(data)) {
+		t.Errorf("SendStream() sent = %d, want %d", sent, len(data))
+	}
+	if want := 2*syncHeaderSize + len(data); len(written) != want {
+		t.Errorf("written %d bytes, want %d", len(written), want)
+	}
+	if size := binary.LittleEndian.Uint32(written[4:8]); size != syncMaxChunkSize {
+		t.Errorf("first chunk size = %d, want %d", size, syncMaxChunkSize)
+	}
+}
@@ -0,0 +1,28 @@
+package gadb
+
+import (
+	"context"
+	"fmt"
+)
+
+// TailFile calls onLine with each of the last lines lines of the remote file,
+// then with each line appended to it if follow is set, until ctx is done.
+// Following survives the rotation of the file where the device tail supports
+// -F, and falls back to -f otherwise.
+func (d Device) TailFile(ctx context.Context, remotePath string, lines int, follow bool, onLine func(string)) error {
+	return d.RunShellCommandLines(ctx, func(line string) bool {
+		onLine(line)
+		return true
+	}, tailCommand(remotePath, lines, follow))
+}
+
+// tailCommand returns the command line of TailFile. Old toybox versions
+// reject -F right away, so -f is only run when the -F run fails.
+func tailCommand(remotePath string, lines int, follow bool) string {
+	tail := fmt.Sprintf("tail -n %d", lines)
+	path := shellQuote(remotePath)
+	if !follow {
+		return fmt.Sprintf("%s %s", tail, path)
+	}
+	return fmt.Sprintf("%s -F %s 2>/dev/null || %s -f %s", tail, path, tail, path)
+}
@@ -0,0 +1,60 @@
+package gadb
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_tailCommand(t *testing.T) {
+	got := tailCommand("/data/local/tmp/app log.txt", 10, false)
+	if want := "tail -n 10 '/data/local/tmp/app log.txt'"; got != want {
+		t.Errorf("tailCommand() = %q, want %q", got, want)
+	}
+
+	got = tailCommand("/data/local/tmp/app.log", 0, true)
+	if want := "tail -n 0 -F /data/local/tmp/app.log 2>/dev/null || tail -n 0 -f /data/local/tmp/app.log"; got != want {
+		t.Errorf("tailCommand() = %q, want %q", got, want)
+	}
+}
+
+func TestDevice_TailFile(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+	dev := devices[0]
+
+	remotePath := "/data/local/tmp/gadb-tail.txt"
+	if _, err := dev.RunShellCommand("echo first > " + remotePath); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _, _ = dev.RunShellCommand("rm", "-f", remotePath) }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	go func() {
+		time.Sleep(time.Second)
+		_, _ = dev.RunShellCommand("echo second >> " + remotePath)
+	}()
+
+	var lines []string
+	_ = dev.TailFile(ctx, remotePath, 1, true, func(line string) {
+		lines = append(lines, line)
+		if len(lines) == 2 {
+			cancel()
+		}
+	})
+	if len(lines) != 2 || lines[0] != "first" || lines[1] != "second" {
+		t.Errorf("TailFile() lines = %q", lines)
+	}
+}
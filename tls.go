@@ -0,0 +1,196 @@
+package gadb
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// ErrCertificateMismatch is returned when the certificate presented by a
+// device does not match the public key pinned when pairing
+var ErrCertificateMismatch = errors.New("device certificate does not match the paired key")
+
+// errNoTLS is returned by the TLS methods of a Client not created with one
+// of the NewClient functions
+var errNoTLS = errors.New("client created without NewClient")
+
+// tlsDevices holds the pinned keys and the devices connected with
+// ConnectTLS, shared by all the copies of a Client
+type tlsDevices struct {
+	mu      sync.Mutex
+	pins    map[string][]byte
+	devices map[string]*Device
+	// paired are the hosts paired with Pair whose key is not pinned yet
+	paired map[string]bool
+}
+
+func newTLSDevices() *tlsDevices {
+	return &tlsDevices{
+		pins:    map[string][]byte{},
+		devices: map[string]*Device{},
+		paired:  map[string]bool{},
+	}
+}
+
+// pairedHost records that the device at hostPort was paired with Pair
+func (t *tlsDevices) pairedHost(hostPort string) {
+	host, _, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		host = hostPort
+	}
+
+	t.mu.Lock()
+	t.paired[host] = true
+	t.mu.Unlock()
+}
+
+// WithPairedDevice pins the public key of the device at hostPort, as the DER
+// encoded SubjectPublicKeyInfo of the certificate exchanged when pairing.
+// ConnectTLS only accepts devices presenting this key.
+func WithPairedDevice(hostPort string, spki []byte) ClientOption {
+	return func(c *Client) {
+		c.tls.pins[hostPort] = spki
+	}
+}
+
+// ConnectTLS connects directly to adbd at hostPort using adb over TLS, as
+// used by wireless debugging on Android 11+. cert is the host certificate,
+// whose RSA key must be known to the device. The device must present the
+// key pinned with WithPairedDevice, or, on the first connection to a host
+// paired with Pair, its key is trusted and pinned, see PairedDeviceKey. The
+// device is then available with TLSDevice.
+func (c Client) ConnectTLS(hostPort string, cert tls.Certificate) error {
+	if c.tls == nil {
+		return fmt.Errorf("adb connect tls %s: %w", hostPort, errNoTLS)
+	}
+	key, ok := cert.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		return fmt.Errorf("adb connect tls %s: certificate key must be RSA", hostPort)
+	}
+	host, _, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return fmt.Errorf("adb connect tls %s: %w", hostPort, err)
+	}
+
+	c.tls.mu.Lock()
+	spki, pinned := c.tls.pins[hostPort]
+	paired := c.tls.paired[host]
+	c.tls.mu.Unlock()
+
+	var config *tls.Config
+	var presented []byte
+	switch {
+	case pinned:
+		config = pinnedTLSConfig(cert, spki)
+	case paired:
+		config = peerKeyTLSConfig(cert, func(spki []byte) error {
+			presented = spki
+			return nil
+		})
+	default:
+		return fmt.Errorf("adb connect tls %s: device is not paired", hostPort)
+	}
+
+	dialTimeout := c.dialTimeout
+	if dialTimeout <= 0 {
+		dialTimeout = directDialTimeout
+	}
+	conn, err := net.DialTimeout("tcp", hostPort, dialTimeout)
+	if err != nil {
+		return fmt.Errorf("adb connect tls %s: %w", hostPort, err)
+	}
+
+	dc, err := newDirectConn(conn, key, config, c.readTimeout)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("adb connect tls %s: %w", hostPort, err)
+	}
+
+	d := &Device{
+		adbClient: c,
+		serial:    hostPort,
+		attrs:     parseBanner(dc.banner),
+		direct:    dc,
+	}
+
+	c.tls.mu.Lock()
+	if presented != nil {
+		c.tls.pins[hostPort] = presented
+		delete(c.tls.paired, host)
+	}
+	if old := c.tls.devices[hostPort]; old != nil {
+		old.Close()
+	}
+	c.tls.devices[hostPort] = d
+	c.tls.mu.Unlock()
+	return nil
+}
+
+// PairedDeviceKey returns the public key pinned for the device at hostPort,
+// to be given to WithPairedDevice by the next clients
+func (c Client) PairedDeviceKey(hostPort string) ([]byte, bool) {
+	if c.tls == nil {
+		return nil, false
+	}
+
+	c.tls.mu.Lock()
+	defer c.tls.mu.Unlock()
+
+	spki, ok := c.tls.pins[hostPort]
+	return spki, ok
+}
+
+// TLSDevice returns the device connected with ConnectTLS at hostPort
+func (c Client) TLSDevice(hostPort string) (*Device, error) {
+	if c.tls == nil {
+		return nil, fmt.Errorf("adb tls %s: %w", hostPort, ErrDeviceNotFound)
+	}
+
+	c.tls.mu.Lock()
+	defer c.tls.mu.Unlock()
+
+	d, ok := c.tls.devices[hostPort]
+	if !ok {
+		return nil, fmt.Errorf("adb tls %s: %w", hostPort, ErrDeviceNotFound)
+	}
+	return d, nil
+}
+
+// pinnedTLSConfig returns the configuration of a TLS connection
+// authenticating with cert, and trusting only a peer with the public key spki
+func pinnedTLSConfig(cert tls.Certificate, spki []byte) *tls.Config {
+	return peerKeyTLSConfig(cert, func(peer []byte) error {
+		if !bytes.Equal(peer, spki) {
+			return ErrCertificateMismatch
+		}
+		return nil
+	})
+}
+
+// peerKeyTLSConfig returns the configuration of a TLS connection
+// authenticating with cert, and checking the public key of the peer with
+// verify
+func peerKeyTLSConfig(cert tls.Certificate, verify func(spki []byte) error) *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS13,
+		// Devices present a self-signed certificate, there is no CA to
+		// verify against: check the public key instead
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("no device certificate")
+			}
+			peer, err := x509.ParseCertificate(rawCerts[0])
+			if err != nil {
+				return err
+			}
+			return verify(peer.RawSubjectPublicKeyInfo)
+		},
+	}
+}
@@ -0,0 +1,125 @@
+package gadb
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func selfSignedCert(t *testing.T) (tls.Certificate, *x509.Certificate) {
+	key, err := rsa.GenerateKey(rand.Reader, adbKeyBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "adb"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, parsed
+}
+
+func TestPinnedTLSConfig(t *testing.T) {
+	host, _ := selfSignedCert(t)
+	device, deviceCert := selfSignedCert(t)
+	other, _ := selfSignedCert(t)
+
+	config := pinnedTLSConfig(host, deviceCert.RawSubjectPublicKeyInfo)
+
+	if err := config.VerifyPeerCertificate(device.Certificate, nil); err != nil {
+		t.Errorf("pinned certificate rejected: %v", err)
+	}
+	if err := config.VerifyPeerCertificate(other.Certificate, nil); !errors.Is(err, ErrCertificateMismatch) {
+		t.Errorf("unexpected error for another certificate: %v", err)
+	}
+	if err := config.VerifyPeerCertificate(nil, nil); err == nil {
+		t.Error("expected error without a certificate")
+	}
+}
+
+func TestClient_ConnectTLS_notPaired(t *testing.T) {
+	c := Client{tls: newTLSDevices()}
+	host, _ := selfSignedCert(t)
+
+	if err := c.ConnectTLS("127.0.0.1:1", host); err == nil {
+		t.Error("expected error for an unpaired device")
+	}
+	if _, err := c.TLSDevice("127.0.0.1:1"); !errors.Is(err, ErrDeviceNotFound) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestClient_ConnectTLS_zeroClient(t *testing.T) {
+	host, _ := selfSignedCert(t)
+
+	var c Client
+	if err := c.ConnectTLS("127.0.0.1:1", host); !errors.Is(err, errNoTLS) {
+		t.Errorf("ConnectTLS() error = %v, want %v", err, errNoTLS)
+	}
+	if _, ok := c.PairedDeviceKey("127.0.0.1:1"); ok {
+		t.Error("zero Client has a paired device key")
+	}
+}
+
+func TestClient_Pair_pinsOnConnect(t *testing.T) {
+	c, err := NewClientWithHost("fake", WithDialer(fakeServer(t, func(request string) string {
+		if request == "host:version" {
+			return "OKAY00040029"
+		}
+		resp := "Successfully paired to 127.0.0.1:37000 [guid=adb-1234]"
+		return fmt.Sprintf("OKAY%04x%s", len(resp), resp)
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Pair("127.0.0.1:37000", "123456"); err != nil {
+		t.Fatal(err)
+	}
+	if !c.tls.paired["127.0.0.1"] {
+		t.Error("Pair() did not record the paired host")
+	}
+
+	// The debugging port differs from the pairing one
+	host, _ := selfSignedCert(t)
+	err = c.ConnectTLS("127.0.0.1:1", host)
+	if err == nil || strings.Contains(err.Error(), "not paired") {
+		t.Errorf("ConnectTLS() error = %v, want a connection error", err)
+	}
+}
+
+func TestPeerKeyTLSConfig(t *testing.T) {
+	host, _ := selfSignedCert(t)
+	device, deviceCert := selfSignedCert(t)
+
+	var presented []byte
+	config := peerKeyTLSConfig(host, func(spki []byte) error {
+		presented = spki
+		return nil
+	})
+	if err := config.VerifyPeerCertificate(device.Certificate, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(presented, deviceCert.RawSubjectPublicKeyInfo) {
+		t.Error("verify not called with the key of the device")
+	}
+}
@@ -0,0 +1,224 @@
+package gadb
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TopSample is a refresh of top
+type TopSample struct {
+	// CPU holds the usage of the CPU by category as printed by top, e.g.
+	// "user", "sys", "idle". toybox reports them out of 100% per core, along
+	// with the total as "cpu", e.g. 800 on eight cores. Older toolbox versions
+	// report "user", "system", "iow" and "irq" out of 100%.
+	CPU       map[string]float64
+	Processes []TopProcess
+}
+
+// TopProcess is a process row of top. Fields missing from the output of the
+// device are left to their zero value.
+type TopProcess struct {
+	PID  int
+	User string
+	// CPU and Mem are percentages
+	CPU  float64
+	Mem  float64
+	Name string
+}
+
+// topInterval is the delay between the samples of TopStream
+const topInterval = time.Second
+
+// TopStream runs top refreshing every second and calls onSample with each
+// refresh until ctx is done, then returns nil. Devices whose top lacks batch
+// mode run top once per sample instead.
+func (d Device) TopStream(ctx context.Context, onSample func(TopSample)) error {
+	samples := 0
+	p := topParser{onSample: func(s TopSample) {
+		samples++
+		onSample(s)
+	}}
+	err := d.RunShellCommandLines(ctx, p.parseLine, "top", "-b", "-d", "1")
+	if err != nil && ctx.Err() == nil {
+		return err
+	}
+	p.flush()
+	if samples > 0 || ctx.Err() != nil {
+		return nil
+	}
+
+	// The toolbox top rejects -b, but takes a second to print each refresh
+	for {
+		samples = 0
+		var last string
+		p = topParser{onSample: func(s TopSample) {
+			samples++
+			onSample(s)
+		}}
+		err = d.RunShellCommandLines(ctx, func(line string) bool {
+			if strings.TrimSpace(line) != "" {
+				last = line
+			}
+			return p.parseLine(line)
+		}, "top", "-n", "1", "-d", "1")
+		if err != nil && ctx.Err() == nil {
+			return err
+		}
+		p.flush()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if samples == 0 {
+			return fmt.Errorf("adb top: no sample in the output: %q", last)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(topInterval):
+		}
+	}
+}
+
+// topParser parses the refreshes of top, each starting with the CPU usage
+// line and ending with the process rows. The columns of the rows are mapped
+// by the header names.
+type topParser struct {
+	onSample func(TopSample)
+
+	sample  *TopSample
+	header  []string
+	columns map[string]int
+}
+
+func (p *topParser) parseLine(line string) bool {
+	fields := strings.Fields(line)
+	switch {
+	case len(fields) == 0:
+	case isTopCPULine(fields):
+		p.flush()
+		p.sample = &TopSample{CPU: parseTopCPU(fields)}
+	case p.sample == nil:
+	case fields[0] == "PID":
+		p.setHeader(fields)
+	case p.header != nil:
+		if proc, ok := p.parseRow(fields); ok {
+			p.sample.Processes = append(p.sample.Processes, proc)
+		}
+	}
+	return true
+}
+
+// flush reports the current sample, if any
+func (p *topParser) flush() {
+	if p.sample != nil {
+		p.onSample(*p.sample)
+	}
+	p.sample, p.header = nil, nil
+}
+
+// isTopCPULine reports whether fields are the CPU usage line of toybox, e.g.
+// "800%cpu 12%user 0%nice 13%sys 774%idle", or of toolbox, e.g. "User 5%,
+// System 3%, IOW 0%, IRQ 0%"
+func isTopCPULine(fields []string) bool {
+	return strings.HasSuffix(fields[0], "%cpu") ||
+		fields[0] == "User" && len(fields) > 1 && strings.HasSuffix(fields[1], "%,")
+}
+
+func parseTopCPU(fields []string) map[string]float64 {
+	cpu := map[string]float64{}
+	if fields[0] == "User" {
+		for i := 0; i+1 < len(fields); i += 2 {
+			cpu[strings.ToLower(fields[i])] = parsePercent(fields[i+1])
+		}
+		return cpu
+	}
+
+	for _, f := range fields {
+		kv := strings.SplitN(f, "%", 2)
+		if len(kv) == 2 && kv[1] != "" {
+			cpu[kv[1]] = parsePercent(kv[0])
+		}
+	}
+	return cpu
+}
+
+// setHeader maps the columns, splitting the "S[%CPU]" header of toybox, whose
+// rows have the two columns apart
+func (p *topParser) setHeader(fields []string) {
+	p.header = p.header[:0]
+	for _, f := range fields {
+		if i := strings.Index(f, "["); i > 0 && strings.HasSuffix(f, "]") {
+			p.header = append(p.header, f[:i], f[i+1:len(f)-1])
+			continue
+		}
+		p.header = append(p.header, f)
+	}
+
+	p.columns = map[string]int{}
+	for i, name := range p.header {
+		p.columns[name] = i
+	}
+}
+
+func (p *topParser) parseRow(fields []string) (TopProcess, bool) {
+	pidCol, ok := p.columns["PID"]
+	if !ok || pidCol >= len(fields) {
+		return TopProcess{}, false
+	}
+	pid, err := strconv.Atoi(fields[pidCol])
+	if err != nil {
+		return TopProcess{}, false
+	}
+	proc := TopProcess{PID: pid}
+
+	// The name is last and may hold spaces with the ARGS of toybox. Toolbox
+	// leaves the PCY column blank for some processes, the columns right of
+	// it are then found from the end of the row.
+	gap, hasGap := p.columns["PCY"]
+	field := func(names ...string) (string, bool) {
+		for _, name := range names {
+			i, ok := p.columns[name]
+			if !ok {
+				continue
+			}
+			if len(fields) < len(p.header) && hasGap && i > gap {
+				i = len(fields) - (len(p.header) - i)
+			}
+			if i >= 0 && i < len(fields) {
+				return fields[i], true
+			}
+		}
+		return "", false
+	}
+	if v, ok := field("%CPU", "CPU%"); ok {
+		proc.CPU = parsePercent(v)
+	}
+	if v, ok := field("%MEM"); ok {
+		proc.Mem = parsePercent(v)
+	}
+	if v, ok := field("USER", "UID"); ok {
+		proc.User = v
+	}
+	for _, name := range []string{"ARGS", "CMD", "COMMAND", "NAME", "Name"} {
+		i, ok := p.columns[name]
+		if !ok {
+			continue
+		}
+		if i == len(p.header)-1 && len(fields) >= len(p.header) {
+			proc.Name = strings.Join(fields[i:], " ")
+		} else {
+			proc.Name = fields[len(fields)-1]
+		}
+		break
+	}
+	return proc, true
+}
+
+func parsePercent(s string) float64 {
+	f, _ := strconv.ParseFloat(strings.TrimRight(s, "%,"), 64)
+	return f
+}
@@ -0,0 +1,136 @@
+package gadb
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_topParser(t *testing.T) {
+	tests := map[string]struct {
+		output string
+		want   []TopSample
+	}{
+		"toybox": {
+			output: "Tasks: 512 total,   1 running, 511 sleeping,   0 stopped,   0 zombie\n" +
+				"  Mem:  3797916K total,  3591416K used,   206500K free,    23384K buffers\n" +
+				"800%cpu  12%user   0%nice  13%sys 774%idle   0%iow   0%irq   1%sirq   0%host\n" +
+				"  PID USER         PR  NI VIRT  RES  SHR S[%CPU] %MEM     TIME+ ARGS\n" +
+				" 5432 shell        20   0  10G 4.1M 3.2M R 10.3   0.1   0:00.03 top -b -d 1\n" +
+				"  612 root         20   0 4.2G 150M 120M S  1.0   3.9   1:23.45 zygote64\n" +
+				"Tasks: 512 total,   1 running, 511 sleeping,   0 stopped,   0 zombie\n" +
+				"800%cpu   8%user   0%nice   6%sys 786%idle   0%iow   0%irq   0%sirq   0%host\n" +
+				"  PID USER         PR  NI VIRT  RES  SHR S[%CPU] %MEM     TIME+ ARGS\n" +
+				"  612 root         20   0 4.2G 150M 120M S  2.0   3.9   1:23.47 zygote64\n",
+			want: []TopSample{
+				{
+					CPU: map[string]float64{"cpu": 800, "user": 12, "nice": 0, "sys": 13, "idle": 774, "iow": 0, "irq": 0, "sirq": 1, "host": 0},
+					Processes: []TopProcess{
+						{PID: 5432, User: "shell", CPU: 10.3, Mem: 0.1, Name: "top -b -d 1"},
+						{PID: 612, User: "root", CPU: 1, Mem: 3.9, Name: "zygote64"},
+					},
+				},
+				{
+					CPU: map[string]float64{"cpu": 800, "user": 8, "nice": 0, "sys": 6, "idle": 786, "iow": 0, "irq": 0, "sirq": 0, "host": 0},
+					Processes: []TopProcess{
+						{PID: 612, User: "root", CPU: 2, Mem: 3.9, Name: "zygote64"},
+					},
+				},
+			},
+		},
+		"toolbox": {
+			output: "\r\n" +
+				"User 5%, System 3%, IOW 0%, IRQ 0%\r\n" +
+				"User 20 + Nice 0 + Sys 12 + Idle 350 + IOW 0 + IRQ 0 + SIRQ 0 = 382\r\n" +
+				"\r\n" +
+				"  PID PR CPU% S  #THR     VSS     RSS PCY UID      Name\r\n" +
+				" 2345  0   3% S    12  512345K  40123K  fg u0_a12   com.example.app\r\n" +
+				"    1  0   0% S     1    1234K    456K     root     /init\r\n",
+			want: []TopSample{
+				{
+					CPU: map[string]float64{"user": 5, "system": 3, "iow": 0, "irq": 0},
+					Processes: []TopProcess{
+						{PID: 2345, User: "u0_a12", CPU: 3, Name: "com.example.app"},
+						{PID: 1, User: "root", Name: "/init"},
+					},
+				},
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		var got []TopSample
+		p := topParser{onSample: func(s TopSample) { got = append(got, s) }}
+		for _, l := range strings.Split(tt.output, "\n") {
+			p.parseLine(strings.TrimRight(l, "\r"))
+		}
+		p.flush()
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: samples = %+v, want %+v", name, got, tt.want)
+		}
+	}
+}
+
+func TestDevice_TopStream_noSample(t *testing.T) {
+	runs := 0
+	d := Device{
+		adbClient: Client{readTimeout: defaultAdbReadTimeout, dial: shellServer(t, func(cmd string) string {
+			runs++
+			return "/system/bin/sh: top: not found\n"
+		})},
+		serial: "fake",
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := d.TopStream(ctx, func(TopSample) {})
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("TopStream() error = %v, want the output of top", err)
+	}
+	if runs != 2 {
+		t.Errorf("top run %d times, want 2", runs)
+	}
+}
+
+func TestDevice_TopStream_cancel(t *testing.T) {
+	output := "800%cpu  12%user   0%nice  13%sys 774%idle   0%iow   0%irq   1%sirq   0%host\n" +
+		"  PID USER         PR  NI VIRT  RES  SHR S[%CPU] %MEM     TIME+ ARGS\n" +
+		"  612 root         20   0 4.2G 150M 120M S  1.0   3.9   1:23.45 zygote64\n"
+	written := make(chan struct{})
+	d := Device{
+		adbClient: Client{readTimeout: defaultAdbReadTimeout, dial: fakeServer(t, func(string) string {
+			return "OKAY"
+		}, func(_ string, conn net.Conn) {
+			// The blank line is only read once the sample lines are parsed,
+			// then top keeps running until the connection is closed
+			_, _ = conn.Write([]byte(output))
+			_, _ = conn.Write([]byte("\n"))
+			close(written)
+			_, _ = io.Copy(ioutil.Discard, conn)
+		})},
+		serial: "fake",
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-written
+		cancel()
+	}()
+
+	var samples []TopSample
+	err := d.TopStream(ctx, func(s TopSample) {
+		samples = append(samples, s)
+	})
+	if err != nil {
+		t.Errorf("TopStream() = %v, want nil", err)
+	}
+	if len(samples) != 1 || len(samples[0].Processes) != 1 {
+		t.Errorf("samples = %+v, want the sample printed before cancel", samples)
+	}
+}
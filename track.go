@@ -0,0 +1,188 @@
+package gadb
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DeviceEvent is a change in the state of a device. A device that is no
+// longer connected is reported with StateDisconnected.
+type DeviceEvent struct {
+	Serial string
+	State  DeviceState
+}
+
+// TrackDevices streams the changes of state of the devices connected to the
+// adb server. The devices connected when tracking starts are reported first.
+// The channel is closed when ctx is done or the connection breaks.
+func (c Client) TrackDevices(ctx context.Context) (<-chan DeviceEvent, error) {
+	tp, err := c.createTrackDevicesTransport()
+	if err != nil {
+		return nil, err
+	}
+	untrack, err := c.track(tp.sock)
+	if err != nil {
+		tp.Close()
+		return nil, err
+	}
+
+	events := make(chan DeviceEvent)
+	go func() {
+		defer close(events)
+		defer untrack()
+		defer tp.Close()
+
+		stop := closeOnCancel(ctx, tp)
+		defer stop()
+
+		known := map[string]DeviceState{}
+		for {
+			resp, err := tp.UnpackString()
+			if err != nil {
+				return
+			}
+
+			current := parseDeviceStates(resp)
+			for _, event := range diffDeviceStates(known, current) {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+			known = current
+		}
+	}()
+
+	return events, nil
+}
+
+// WaitForDevice blocks until the device with the given serial reaches state.
+// StateDisconnected waits for the device to go away. ctx.Err() is returned if
+// ctx is done first.
+func (c Client) WaitForDevice(ctx context.Context, serial string, state DeviceState) error {
+	tp, err := c.createTrackDevicesTransport()
+	if err != nil {
+		return err
+	}
+	defer tp.Close()
+
+	untrack, err := c.track(tp.sock)
+	if err != nil {
+		return err
+	}
+	defer untrack()
+
+	stop := closeOnCancel(ctx, tp)
+	defer stop()
+
+	for {
+		resp, err := tp.UnpackString()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+
+		current, ok := parseDeviceStates(resp)[serial]
+		if !ok {
+			current = StateDisconnected
+		}
+		if current == state {
+			return nil
+		}
+	}
+}
+
+// bootPollInterval is the interval between two checks of WaitForBootComplete
+const bootPollInterval = time.Second
+
+// WaitForBootComplete blocks until the device has finished booting, e.g.
+// after Reboot, polling sys.boot_completed. Errors while the device is still
+// unreachable are retried. ctx.Err() is returned if ctx is done first.
+func (d Device) WaitForBootComplete(ctx context.Context) error {
+	ticker := time.NewTicker(bootPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if d.bootCompleted(ctx) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d Device) bootCompleted(ctx context.Context) bool {
+	// dev.bootcomplete is the only one set by some old devices
+	for _, prop := range []string{"sys.boot_completed", "dev.bootcomplete"} {
+		output, err := d.RunShellCommandContext(ctx, "getprop", prop)
+		if err == nil && strings.TrimSpace(output) == "1" {
+			return true
+		}
+	}
+	return false
+}
+
+// createTrackDevicesTransport returns a transport streaming the snapshots of
+// host:track-devices, the first of them being the currently connected devices
+func (c Client) createTrackDevicesTransport() (transport, error) {
+	tp, err := c.createTransport()
+	if err != nil {
+		return transport{}, err
+	}
+
+	err = tp.Send("host:track-devices")
+	if err != nil {
+		tp.Close()
+		return transport{}, err
+	}
+
+	err = tp.VerifyResponse()
+	if err != nil {
+		tp.Close()
+		return transport{}, err
+	}
+
+	// Snapshots only arrive on changes, so the connection may idle for long
+	tp.readTimeout = 0
+	return tp, nil
+}
+
+// parseDeviceStates parses the "<serial>\t<state>" lines of host:devices
+func parseDeviceStates(resp string) map[string]DeviceState {
+	states := map[string]DeviceState{}
+	for _, l := range strings.Split(resp, "\n") {
+		fields := strings.Fields(l)
+		if len(fields) < 2 {
+			continue
+		}
+		states[fields[0]] = deviceStateConv(fields[1])
+	}
+	return states
+}
+
+// diffDeviceStates returns the events turning previous into current, ordered by serial
+func diffDeviceStates(previous, current map[string]DeviceState) []DeviceEvent {
+	var events []DeviceEvent
+	for serial, state := range current {
+		if prevState, ok := previous[serial]; !ok || prevState != state {
+			events = append(events, DeviceEvent{Serial: serial, State: state})
+		}
+	}
+	for serial := range previous {
+		if _, ok := current[serial]; !ok {
+			events = append(events, DeviceEvent{Serial: serial, State: StateDisconnected})
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool { return events[i].Serial < events[j].Serial })
+	return events
+}
@@ -0,0 +1,87 @@
+package gadb
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_diffDeviceStates(t *testing.T) {
+	previous := parseDeviceStates("emulator-5554\tdevice\n0123456789\toffline\nremoved\tdevice\n")
+	current := parseDeviceStates("emulator-5554\tdevice\n0123456789\tdevice\nadded\tunauthorized\n")
+
+	want := []DeviceEvent{
+		{Serial: "0123456789", State: StateOnline},
+		{Serial: "added", State: StateUnauthorized},
+		{Serial: "removed", State: StateDisconnected},
+	}
+	if got := diffDeviceStates(previous, current); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffDeviceStates() = %v, want %v", got, want)
+	}
+}
+
+func TestClient_TrackDevices(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	events, err := c.TrackDevices(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for event := range events {
+		t.Log(event.Serial, event.State)
+	}
+}
+
+func TestClient_WaitForDevice(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = c.WaitForDevice(ctx, devices[0].Serial(), StateOnline)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDevice_WaitForBootComplete(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := devices[0].WaitForBootComplete(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,38 +1,65 @@
 package gadb
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net"
 	"strconv"
+	"syscall"
 	"time"
 )
 
 // ErrConnBroken is returned when the connection is broken
 var ErrConnBroken = errors.New("socket connection broken")
 
+// errHalfCloseUnsupported is returned when the connection cannot be closed
+// for writing only, e.g. with a custom DialFunc
+var errHalfCloseUnsupported = errors.New("half-close unsupported")
+
 const (
 	defaultAdbReadTimeout = 60 * time.Second
+	defaultDialTimeout    = 10 * time.Second
 )
 
 type transport struct {
 	sock        net.Conn
 	readTimeout time.Duration
+	// syncChunkSize overrides the chunk size of the sync transports if set
+	syncChunkSize int
+	// tracer is called with the services opened on the transport, if set
+	tracer Tracer
+}
+
+func newTransport(dial DialFunc, address string, dialTimeout, readTimeout time.Duration) (transport, error) {
+	sock, err := dialServer(dial, address, dialTimeout)
+	if err != nil {
+		return transport{readTimeout: readTimeout}, err
+	}
+	return transport{sock: sock, readTimeout: readTimeout}, nil
 }
 
-func newTransport(address string) (transport, error) {
-	tp := transport{
-		readTimeout: defaultAdbReadTimeout,
+// dialServer connects to the adb server at address with dial, or a
+// net.Dialer if nil, giving up after timeout if positive
+func dialServer(dial DialFunc, address string, timeout time.Duration) (net.Conn, error) {
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
 	}
 
-	var err error
-	tp.sock, err = net.Dial("tcp", address)
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	sock, err := dial(ctx, "tcp", address)
 	if err != nil {
-		return tp, fmt.Errorf("adb transport: %w", err)
+		// net.OpError names the address already
+		return nil, fmt.Errorf("adb transport: %w", err)
 	}
-	return tp, nil
+	return sock, nil
 }
 
 func (t transport) Send(command string) error {
@@ -53,7 +80,7 @@ func (t transport) VerifyResponse() error {
 	if err != nil {
 		return err
 	}
-	return fmt.Errorf("command failed: %s", sError)
+	return newAdbError(sError)
 }
 
 func (t transport) ReadStringAll() (string, error) {
@@ -93,7 +120,7 @@ func (t transport) ReadStringN(size int) (string, error) {
 }
 
 func (t transport) ReadBytesN(size int) (raw []byte, err error) {
-	_ = t.sock.SetReadDeadline(time.Now().Add(t.readTimeout))
+	_ = t.sock.SetReadDeadline(readDeadline(t.readTimeout))
 	return _readN(t.sock, size)
 }
 
@@ -104,8 +131,13 @@ func (t transport) Close() error {
 	return t.sock.Close()
 }
 
-func (t transport) CreateSyncTransport() (syncTransport, error) {
-	err := t.Send("sync:")
+func (t transport) CreateSyncTransport() (_ syncTransport, err error) {
+	if t.tracer != nil {
+		// The requests exchanged on the sync service are not traced
+		defer trace(t.tracer, "sync:", time.Now(), &err)
+	}
+
+	err = t.Send("sync:")
 	if err != nil {
 		return syncTransport{}, fmt.Errorf("failed to send sync command: %w", err)
 	}
@@ -115,7 +147,11 @@ func (t transport) CreateSyncTransport() (syncTransport, error) {
 		return syncTransport{}, fmt.Errorf("failed to verify sync response: %w", err)
 	}
 
-	return newSyncTransport(t.sock, t.readTimeout), nil
+	sync := newSyncTransport(t.sock, t.readTimeout)
+	if t.syncChunkSize > 0 {
+		sync.chunkSize = t.syncChunkSize
+	}
+	return sync, nil
 }
 
 // CreateShellTransport returns a transport useful for the shell protocol.
@@ -124,6 +160,45 @@ func (t transport) CreateShellTransport() (sTp shellTransport, err error) {
 	return
 }
 
+// timeoutConn applies the read timeout to every Read, making it an idle
+// timeout for streamed responses
+type timeoutConn struct {
+	net.Conn
+	readTimeout time.Duration
+}
+
+func (c timeoutConn) Read(p []byte) (int, error) {
+	_ = c.Conn.SetReadDeadline(readDeadline(c.readTimeout))
+	return c.Conn.Read(p)
+}
+
+// CloseWrite half-closes the connection, if it supports it
+func (c timeoutConn) CloseWrite() error {
+	cw, ok := c.Conn.(interface{ CloseWrite() error })
+	if !ok {
+		return errHalfCloseUnsupported
+	}
+	return cw.CloseWrite()
+}
+
+// readDeadline returns the deadline for a read starting now, a non positive
+// timeout disables the deadline
+func readDeadline(timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(timeout)
+}
+
+// isDisconnect reports whether err is caused by the remote end closing the connection
+func isDisconnect(err error) bool {
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, ErrConnBroken) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, net.ErrClosed)
+}
+
 func _send(writer io.Writer, msg []byte) error {
 	for totalSent := 0; totalSent < len(msg); {
 		sent, err := writer.Write(msg[totalSent:])
@@ -7,7 +7,7 @@ import (
 func Test_transport_VerifyResponse(t *testing.T) {
 	
 
-	transport, err := newTransport("localhost:5037")
+	transport, err := newTransport(nil, "localhost:5037", defaultDialTimeout, defaultAdbReadTimeout)
 	if err != nil {
 		t.Fatal(err)
 	}
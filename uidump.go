@@ -0,0 +1,53 @@
+package gadb
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	uiDumpStart = []byte("<?xml")
+	uiDumpEnd   = []byte("</hierarchy>")
+)
+
+// UIDump returns the XML window hierarchy dumped by uiautomator. The dump is
+// written to /dev/tty first, and to a temporary file under /data/local/tmp
+// which is pulled then removed when that fails, as it does on some devices.
+func (d Device) UIDump() ([]byte, error) {
+	raw, err := d.Exec("uiautomator", "dump", "/dev/tty")
+	if err == nil {
+		if xml, ok := extractUIDump(raw); ok {
+			return xml, nil
+		}
+	}
+
+	remotePath := fmt.Sprintf("%s/gadb-uidump-%d.xml", tempDir, time.Now().UnixNano())
+	output, err := d.RunShellCommand("uiautomator", "dump", remotePath)
+	if err != nil {
+		return nil, fmt.Errorf("adb uiautomator dump: %w", err)
+	}
+	defer func() { _, _ = d.RunShellCommand("rm", "-f", remotePath) }()
+
+	raw, err = d.ReadFile(remotePath)
+	if err != nil {
+		return nil, fmt.Errorf("adb uiautomator dump: %s: %w", strings.TrimSpace(output), err)
+	}
+	xml, ok := extractUIDump(raw)
+	if !ok {
+		return nil, fmt.Errorf("adb uiautomator dump: invalid hierarchy: %q", truncate(raw, 64))
+	}
+	return xml, nil
+}
+
+// extractUIDump returns the XML document of the output of uiautomator dump,
+// without the "UI hierchary dumped to" message that follows it
+func extractUIDump(raw []byte) ([]byte, bool) {
+	start := bytes.Index(raw, uiDumpStart)
+	end := bytes.LastIndex(raw, uiDumpEnd)
+	if start < 0 || end < start {
+		return nil, false
+	}
+	return raw[start : end+len(uiDumpEnd)], true
+}
@@ -0,0 +1,42 @@
+package gadb
+
+import (
+	"bytes"
+	"testing"
+)
+
+func Test_extractUIDump(t *testing.T) {
+	xml := `<?xml version='1.0' encoding='UTF-8' standalone='yes' ?><hierarchy rotation="0"><node index="0" text="" /></hierarchy>`
+
+	got, ok := extractUIDump([]byte(xml + "UI hierchary dumped to: /dev/tty\n"))
+	if !ok || string(got) != xml {
+		t.Errorf("extractUIDump() = %q, %v, want %q", got, ok, xml)
+	}
+
+	if _, ok := extractUIDump([]byte("ERROR: null root node returned by UiTestAutomationBridge.\n")); ok {
+		t.Error("expected no hierarchy")
+	}
+}
+
+func TestDevice_UIDump(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	xml, err := devices[0].UIDump()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(xml, []byte("<hierarchy")) {
+		t.Errorf("UIDump() = %q", truncate(xml, 64))
+	}
+}
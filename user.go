@@ -0,0 +1,31 @@
+package gadb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CurrentUser returns the ID of the user in the foreground, 0 unless another
+// user or a guest switched in
+func (d Device) CurrentUser() (int, error) {
+	output, err := d.RunShellCommand("am", "get-current-user")
+	if err != nil {
+		return 0, fmt.Errorf("adb am get-current-user: %w", err)
+	}
+
+	id, err := strconv.Atoi(strings.TrimSpace(output))
+	if err != nil {
+		return 0, fmt.Errorf("adb am get-current-user: unexpected output %q", truncate([]byte(output), 64))
+	}
+	return id, nil
+}
+
+// userArgs returns the --user argument of am and pm targeting the user, if
+// not nil
+func userArgs(userID *int) []string {
+	if userID == nil {
+		return nil
+	}
+	return []string{"--user", strconv.Itoa(*userID)}
+}
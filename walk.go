@@ -0,0 +1,131 @@
+package gadb
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Walk walks the remote file tree rooted at root, calling fn for each file
+// or directory in the tree, including root, like filepath.Walk. Entries are
+// walked in lexical order, and fn may return filepath.SkipDir to skip a
+// directory. Symbolic links are not followed.
+func (d Device) Walk(root string, fn filepath.WalkFunc) error {
+	info, err := d.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = d.walk(root, info, fn)
+	}
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+func (d Device) walk(remotePath string, info os.FileInfo, fn filepath.WalkFunc) error {
+	if !info.IsDir() {
+		return fn(remotePath, info, nil)
+	}
+
+	entries, err := d.List(remotePath)
+	err1 := fn(remotePath, info, err)
+	if err != nil || err1 != nil {
+		// The directory is reported a second time with the error of List,
+		// as filepath.Walk does
+		return err1
+	}
+
+	sortEntries(entries)
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == "." || name == ".." {
+			continue
+		}
+
+		err = d.walk(path.Join(remotePath, name), entry, fn)
+		if err != nil {
+			if !entry.IsDir() || err != filepath.SkipDir {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Glob returns the names of all remote files matching pattern, with the
+// syntax of path.Match, or nil if there is no matching file. Like
+// filepath.Glob, errors listing the directories are ignored and the only
+// possible returned error is path.ErrBadPattern.
+func (d Device) Glob(pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	if !hasGlobMeta(pattern) {
+		if _, err := d.Stat(pattern); err != nil {
+			return nil, nil
+		}
+		return []string{pattern}, nil
+	}
+
+	dir, file := path.Split(pattern)
+	dir = cleanGlobDir(dir)
+	if !hasGlobMeta(dir) {
+		return d.glob(dir, file, nil), nil
+	}
+
+	dirs, err := d.Glob(dir)
+	if err != nil {
+		return nil, err
+	}
+	var matches []string
+	for _, dir := range dirs {
+		matches = d.glob(dir, file, matches)
+	}
+	return matches, nil
+}
+
+// glob appends to matches the entries of dir matching pattern
+func (d Device) glob(dir, pattern string, matches []string) []string {
+	entries, err := d.List(dir)
+	if err != nil {
+		return matches
+	}
+
+	sortEntries(entries)
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == "." || name == ".." {
+			continue
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			matches = append(matches, path.Join(dir, name))
+		}
+	}
+	return matches
+}
+
+func sortEntries(entries []os.FileInfo) {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+}
+
+func hasGlobMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
+
+// cleanGlobDir cleans the directory part of a glob pattern
+func cleanGlobDir(dir string) string {
+	switch dir {
+	case "":
+		return "."
+	case "/":
+		return dir
+	default:
+		return dir[:len(dir)-1]
+	}
+}
@@ -0,0 +1,70 @@
+package gadb
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_cleanGlobDir(t *testing.T) {
+	tests := map[string]string{
+		"":                 ".",
+		"/":                "/",
+		"/data/local/tmp/": "/data/local/tmp",
+		"/sdcard/*/":       "/sdcard/*",
+	}
+	for dir, want := range tests {
+		if got := cleanGlobDir(dir); got != want {
+			t.Errorf("cleanGlobDir(%q) = %q, want %q", dir, got, want)
+		}
+	}
+}
+
+func TestDevice_Walk(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) == 0 {
+		t.SkipNow()
+	}
+
+	root := "/data/local/tmp/gadb-walk"
+	_, err = devices[0].RunShellCommandRaw("mkdir -p " + root + "/a/b && touch " + root + "/a/b/heap.hprof " + root + "/a/other.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devices[0].RunShellCommand("rm", "-r", root)
+
+	var found []string
+	err = devices[0].Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if ok, _ := filepath.Match("*.hprof", info.Name()); ok {
+			found = append(found, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0] != root+"/a/b/heap.hprof" {
+		t.Errorf("Walk() found %q", found)
+	}
+
+	matches, err := devices[0].Glob(root + "/*/*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(matches, ",") != root+"/a/other.txt" {
+		t.Errorf("Glob() = %q", matches)
+	}
+}